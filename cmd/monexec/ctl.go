@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reddec/monexec/monexec"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	ctlCommand      = kingpin.Command("ctl", "Control running supervisor through control socket")
+	ctlSocket       = ctlCommand.Flag("control-socket", "Unix socket of running supervisor").Default(monexec.DefaultControlSocket()).String()
+	ctlStatus       = ctlCommand.Command("status", "Show status of services")
+	ctlStart        = ctlCommand.Command("start", "Start service")
+	ctlStartLabel   = ctlStart.Arg("label", "Service label").Required().String()
+	ctlStop         = ctlCommand.Command("stop", "Stop service")
+	ctlStopLabel    = ctlStop.Arg("label", "Service label or instance ID").Required().String()
+	ctlRestart      = ctlCommand.Command("restart", "Restart service")
+	ctlRestartLabel = ctlRestart.Arg("label", "Service label").Required().String()
+	ctlSignal       = ctlCommand.Command("signal", "Send signal to processes of service")
+	ctlSignalLabel  = ctlSignal.Arg("label", "Service label").Required().String()
+	ctlSignalName   = ctlSignal.Arg("signal", "Signal name (HUP, SIGUSR1) or number").Required().String()
+	ctlLogs         = ctlCommand.Command("logs", "Show last lines of service output")
+	ctlLogsLabel    = ctlLogs.Arg("label", "Service label or instance ID").Required().String()
+	ctlLogsLines    = ctlLogs.Flag("lines", "Number of lines").Short('n').Default("100").Int()
+	ctlReload       = ctlCommand.Command("reload", "Reload configuration")
+)
+
+func ctlClient() *http.Client {
+	return &http.Client{
+		Timeout: 1 * time.Minute,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", *ctlSocket)
+			},
+		},
+	}
+}
+
+// ctlCall makes request to control socket and decodes result. Exits on error
+func ctlCall(method, path string, result interface{}) {
+	if *ctlSocket == "" {
+		fail(errors.New("control socket is not set: use --control-socket"))
+	}
+	req, err := http.NewRequest(method, "http://monexec"+path, nil)
+	if err != nil {
+		fail(err)
+	}
+	res, err := ctlClient().Do(req)
+	if err != nil {
+		fail(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(res.Body).Decode(&msg)
+		fail(fmt.Errorf("%v: %v", res.Status, msg.Error))
+	}
+	if err := json.NewDecoder(res.Body).Decode(result); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
+
+func ctlPost(path string) {
+	var result interface{}
+	ctlCall(http.MethodPost, path, &result)
+	switch v := result.(type) {
+	case []interface{}:
+		for _, item := range v {
+			fmt.Println(item)
+		}
+	default:
+		fmt.Println(v)
+	}
+}
+
+func ctlShowStatus() {
+	var services []monexec.SupervisorInfo
+	ctlCall(http.MethodGet, "/status", &services)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATE\tPID\tUPTIME\tCPU\tRSS\tRESTARTS\tLAST ERROR")
+	for _, sv := range services {
+		if len(sv.Instances) == 0 {
+			fmt.Fprintf(w, "%v\t%v\t\t\t\t\t\t\n", sv.Label, "not running")
+			continue
+		}
+		for _, in := range sv.Instances {
+			pid := ""
+			if in.PID != 0 {
+				pid = strconv.Itoa(in.PID)
+			}
+			cpu, rss := "", ""
+			if in.Usage != nil {
+				cpu = fmt.Sprintf("%.1f%%", in.Usage.CPUPercent)
+				rss = fmt.Sprintf("%.1fM", float64(in.Usage.RSS)/(1<<20))
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", in.ID, in.State, pid, in.Uptime.Truncate(time.Second), cpu, rss, in.Restarts, in.LastError)
+		}
+	}
+	w.Flush()
+}
+
+func ctlShowLogs() {
+	var logs map[string][]string
+	ctlCall(http.MethodGet, "/logs/"+url.PathEscape(*ctlLogsLabel)+"?lines="+strconv.Itoa(*ctlLogsLines), &logs)
+	var ids []string
+	for id := range logs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if len(ids) > 1 {
+			fmt.Println("==>", id, "<==")
+		}
+		for _, line := range logs[id] {
+			fmt.Println(line)
+		}
+	}
+}
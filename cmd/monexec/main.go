@@ -8,6 +8,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/alecthomas/kingpin.v2"
 	"gopkg.in/yaml.v2"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -29,6 +30,7 @@ var (
 	runEnv          = runCommand.Flag("env", "Environment addition variables").Short('e').StringMap()
 	runEnvFiles     = runCommand.Flag("env-file", "Files with additional environment variables").Short('E').Strings()
 	runRawOutput    = runCommand.Flag("raw", "Raw stdout without prefixes").Short('R').Bool()
+	runSocket       = runCommand.Flag("control-socket", "Unix socket for control API (empty to disable)").Default("").String()
 
 	runConsulEnable    = runCommand.Flag("consul", "Enable consul integration").Bool()
 	runConsulAddress   = runCommand.Flag("consul-address", "Consul address").Default("http://localhost:8500").String()
@@ -40,6 +42,7 @@ var (
 var (
 	startCommand = kingpin.Command("start", "Start supervisor from configuration files")
 	startSources = startCommand.Arg("source", "Source files and/or directories with YAML files (.yml or .yaml)").Required().Strings()
+	startSocket  = startCommand.Flag("control-socket", "Unix socket for control API (empty to disable)").Default(monexec.DefaultControlSocket()).String()
 )
 
 //执行run命令
@@ -86,7 +89,7 @@ func run() {
 		}
 	} else {
 
-		runConfigInSupervisor(&config, &pool.Pool{})
+		runConfigInSupervisor(&config, &pool.Pool{}, *runSocket)
 	}
 }
 
@@ -97,12 +100,24 @@ func start() {
 		log.Fatal(err)
 	}
 
-	runConfigInSupervisor(config, &pool.Pool{})
+	runConfigInSupervisor(config, &pool.Pool{}, *startSocket)
 }
 
-func runConfigInSupervisor(config *monexec.Config, pool *pool.Pool) {
+func runConfigInSupervisor(config *monexec.Config, pool *pool.Pool, socket string) {
 	ctx, stop := context.WithCancel(context.Background())
 
+	if socket != "" {
+		control, err := monexec.ListenControl(ctx, socket, pool)
+		if err == nil {
+			defer control.Close()
+		} else if socket == monexec.DefaultControlSocket() {
+			// 默认的控制接口不可用(例如另一个monexec正在使用)时不影响启动
+			log.Warnln("control socket disabled:", err)
+		} else {
+			log.Fatal(err)
+		}
+	}
+
 	c := make(chan os.Signal, 2)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
 	go func() {
@@ -112,6 +127,17 @@ func runConfigInSupervisor(config *monexec.Config, pool *pool.Pool) {
 		}
 	}()
 
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Infoln("SIGHUP received: reloading configuration")
+			if err := pool.Reload(); err != nil {
+				log.Errorln("reload configuration:", err)
+			}
+		}
+	}()
+
 	err := config.Run(ctx, pool)
 	if err != nil {
 		log.Fatal(err)
@@ -131,11 +157,26 @@ func runConfigInSupervisor(config *monexec.Config, pool *pool.Pool) {
 }
 
 func main() {
+	pool.ShimMain()
 	kingpin.Version(version).DefaultEnvars()
 	switch kingpin.Parse() {
 	case "run":
 		run()
 	case "start":
 		start()
+	case ctlStatus.FullCommand():
+		ctlShowStatus()
+	case ctlStart.FullCommand():
+		ctlPost("/start/" + *ctlStartLabel)
+	case ctlStop.FullCommand():
+		ctlPost("/stop/" + *ctlStopLabel)
+	case ctlRestart.FullCommand():
+		ctlPost("/restart/" + *ctlRestartLabel)
+	case ctlSignal.FullCommand():
+		ctlPost("/signal/" + *ctlSignalLabel + "?signal=" + url.QueryEscape(*ctlSignalName))
+	case ctlLogs.FullCommand():
+		ctlShowLogs()
+	case ctlReload.FullCommand():
+		ctlPost("/reload")
 	}
 }
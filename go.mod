@@ -1,35 +1,61 @@
 module github.com/reddec/monexec
 
-go 1.16
+go 1.20
 
 require (
-	github.com/Masterminds/semver v1.2.2 // indirect
 	github.com/Masterminds/sprig v2.15.0+incompatible
 	github.com/Pallinder/go-randomdata v0.0.0-20180505152823-b073033ef5a7
-	github.com/aokoli/goutils v0.0.0-20140502001128-9c37978a95bd // indirect
 	github.com/deckarep/golang-set v1.7.1
 	github.com/fsnotify/fsnotify v1.4.7
 	github.com/gin-gonic/gin v1.4.0
+	github.com/hashicorp/consul/api v1.1.0
+	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
+	github.com/mitchellh/mapstructure v1.1.2
+	github.com/pkg/errors v0.8.1
+	github.com/sirupsen/logrus v1.8.0
+	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/telegram-bot-api.v4 v4.6.2
+	gopkg.in/yaml.v2 v2.2.4
+)
+
+require (
+	github.com/Masterminds/semver v1.2.2 // indirect
+	github.com/alecthomas/template v0.0.0-20160405071501-a0175ee3bccc // indirect
+	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
+	github.com/aokoli/goutils v0.0.0-20140502001128-9c37978a95bd // indirect
+	github.com/armon/go-metrics v0.0.0-20180917152333-f0300d1749da // indirect
+	github.com/gin-contrib/sse v0.0.0-20190301062529-5545eab6dad3 // indirect
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible // indirect
+	github.com/golang/protobuf v1.3.2 // indirect
+	github.com/google/btree v1.0.0 // indirect
 	github.com/google/uuid v0.0.0-20161128191214-064e2069ce9c // indirect
-	github.com/hashicorp/consul/api v1.1.0
+	github.com/hashicorp/go-cleanhttp v0.5.1 // indirect
+	github.com/hashicorp/go-immutable-radix v1.0.0 // indirect
 	github.com/hashicorp/go-msgpack v1.1.5 // indirect
+	github.com/hashicorp/go-rootcerts v1.0.0 // indirect
 	github.com/hashicorp/go-uuid v1.0.2 // indirect
+	github.com/hashicorp/golang-lru v0.5.1 // indirect
 	github.com/hashicorp/memberlist v0.2.2 // indirect
+	github.com/hashicorp/serf v0.8.2 // indirect
 	github.com/huandu/xstrings v0.0.0-20151130125119-3959339b3335 // indirect
 	github.com/imdario/mergo v0.0.0-20171009183408-7fe0c75c13ab // indirect
-	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible
+	github.com/jonboulle/clockwork v0.1.0 // indirect
+	github.com/json-iterator/go v1.1.6 // indirect
+	github.com/kr/pretty v0.1.0 // indirect
 	github.com/lestrrat-go/strftime v1.0.4 // indirect
+	github.com/magefile/mage v1.10.0 // indirect
+	github.com/mattn/go-isatty v0.0.7 // indirect
+	github.com/mitchellh/go-homedir v1.0.0 // indirect
 	github.com/mitchellh/go-testing-interface v1.14.1 // indirect
-	github.com/mitchellh/mapstructure v1.1.2
+	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
+	github.com/modern-go/reflect2 v1.0.1 // indirect
 	github.com/pascaldekloe/goe v0.1.0 // indirect
-	github.com/pkg/errors v0.8.1
-	github.com/sirupsen/logrus v1.8.0
-	github.com/spf13/viper v1.7.1
 	github.com/stretchr/testify v1.6.1 // indirect
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	github.com/ugorji/go v1.1.4 // indirect
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9 // indirect
 	golang.org/x/net v0.0.0-20201031054903-ff519b6c9102 // indirect
-	gopkg.in/alecthomas/kingpin.v2 v2.2.6
-	gopkg.in/telegram-bot-api.v4 v4.6.2
-	gopkg.in/yaml.v2 v2.2.4
+	golang.org/x/sys v0.0.0-20200930185726-fdedc70b468f // indirect
+	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/go-playground/validator.v8 v8.18.2 // indirect
 )
@@ -24,6 +24,13 @@ type Config struct {
 	Services      []pool.Executable                 `yaml:"services"`
 	Plugins       map[string]interface{}            `yaml:",inline"` // all unparsed means plugins
 	loadedPlugins map[string]plugins.PluginConfigNG `yaml:"-"`
+	pluginSources map[string][]pluginSource         `yaml:"-"` // 插件在各配置文件中的原始定义，重载时用于判断插件配置是否变更
+}
+
+// 插件在一个配置文件中的原始定义
+type pluginSource struct {
+	file        string
+	description interface{}
 }
 
 var (
@@ -38,6 +45,7 @@ func DefaultConfig() Config {
 	config := Config{}
 
 	config.loadedPlugins = make(map[string]plugins.PluginConfigNG)
+	config.pluginSources = make(map[string][]pluginSource)
 	return config
 }
 
@@ -64,14 +72,20 @@ func (config *Config) Run(ctx context.Context, p *pool.Pool) error {
 		err := pluginInstance.Prepare(ctx, p)
 		if err != nil {
 			log.Infoln("Failed prepare plugin", pluginName, "-", err)
+			//未就绪的插件在下一次重载时重新加载
+			delete(config.pluginSources, pluginName)
 		} else {
 			log.Infof("Plugin [%v] ready", pluginName)
-			p.Watch(pluginInstance)
 		}
+		//未就绪的插件不接收事件，但仍然记录在Pool中以便查看状态
+		p.WatchPlugin(pluginName, pluginInstance, err)
 	}
 
-	//如果GlobalConfig存在则证明启用了热重载，另存储一份当前运行池Pool和Context
-	if globalConfig != nil {
+	//如果GlobalConfig存在则证明配置是从文件加载的(可以重载)，另存储一份当前运行池Pool和Context
+	//持有gLock: 文件改动触发的重载可能与启动同时发生
+	gLock.Lock()
+	reloadable := globalConfig != nil
+	if reloadable {
 		globalPool = p
 		globalCtx = &ctx
 	}
@@ -81,20 +95,47 @@ func (config *Config) Run(ctx context.Context, p *pool.Pool) error {
 		exec := config.Services[i]
 		FillDefaultExecutable(&exec)
 		p.Add(&exec)
+		if reloadable {
+			runningServices[serviceKey(&config.Services[i])] = &exec
+		}
+	}
+	gLock.Unlock()
+	//服务全部登记后才允许通过SIGHUP、控制接口和REST接口重载
+	if reloadable {
+		p.SetReloader(Reload)
 	}
 
 	p.StartAll(ctx)
 	return nil
 }
 
-//LoadConfig读取一个或多个配置文件
+//LoadConfig读取一个或多个配置文件或目录
 func LoadConfig(locations ...string) (*Config, error) {
+	aggregationConfig, err := parseConfig(false, locations...)
+	if err != nil {
+		return nil, err
+	}
+
+	//从文件加载的配置总是可以通过SIGHUP、控制接口和REST接口重载
+	globalConfig = aggregationConfig
+	reloadLocations = locations
+
+	//监听文件改动必须启用assist插件，因为配置文件热重载参数在此插件中
+	//只有首次读取配置的时候启用了热重载才会监听文件，**如果首次没启用则以后都不会监听了
+	if reloadEnabled(aggregationConfig) {
+		go watchConfig(locations)
+	}
+
+	return aggregationConfig, nil
+}
+
+// parseConfig reads all files and directories, merges them into one configuration and validates it.
+// In strict mode plugins that can't be loaded are errors, otherwise they are skipped
+func parseConfig(strict bool, locations ...string) (*Config, error) {
 	c := DefaultConfig()
 	// 合并后的总配置文件
 	aggregationConfig := &c
 	var files []os.FileInfo
-	var reloadLocation string
-	var reloadFile os.FileInfo
 
 	for _, location := range locations {
 		if stat, err := os.Stat(location); err != nil {
@@ -111,13 +152,7 @@ func LoadConfig(locations ...string) (*Config, error) {
 		}
 
 		for _, info := range files {
-			if strings.HasSuffix(info.Name(), ".yml") || strings.HasSuffix(info.Name(), ".yaml") {
-				//TODO 暂时的逻辑，启用热重载前必须先判断是否是单一配置文件，目前只有单一配置文件才能做热重载
-				if len(locations) == 1 && len(files) == 1 {
-					reloadLocation = location
-					reloadFile = info
-				}
-
+			if isConfigFile(info.Name()) {
 				fileName := path.Join(location, info.Name())
 				data, err := ioutil.ReadFile(fileName)
 				if err != nil {
@@ -126,11 +161,16 @@ func LoadConfig(locations ...string) (*Config, error) {
 				var conf = DefaultConfig()
 				err = yaml.Unmarshal(data, &conf)
 				if err != nil {
-					return nil, err
+					return nil, errors.New(fileName + ": " + err.Error())
 				}
 
 				// -- load all plugins for current config here
-				conf.loadAllPlugins(fileName)
+				if err := conf.loadAllPlugins(fileName); err != nil && strict {
+					return nil, errors.New(fileName + ": " + err.Error())
+				}
+				for pluginName, description := range conf.Plugins {
+					aggregationConfig.pluginSources[pluginName] = append(aggregationConfig.pluginSources[pluginName], pluginSource{file: fileName, description: description})
+				}
 
 				err = aggregationConfig.mergeConfigFrom(&conf)
 				if err != nil {
@@ -140,29 +180,53 @@ func LoadConfig(locations ...string) (*Config, error) {
 		}
 	}
 
-	//只有单一配置文件时才进行热重载判断
-	if reloadFile != nil {
-		//必须启用assist插件，因为配置文件热重载参数在此插件中
-		if plugin, ok := aggregationConfig.loadedPlugins["assist"]; ok {
-			a := plugin.(*plugins.Assist) //Assist指针才实现了PluginConfigNG接口
-			//只有首次读取配置的时候启用了热重载才会加载热重载模块，**如果首次没加载则以后都不会加载了
-			if a.ConfigReload {
-				globalConfig = aggregationConfig
-				go initViper(reloadLocation, reloadFile.Name())
-			}
-		}
+	if err := aggregationConfig.validate(); err != nil {
+		return nil, err
 	}
-
 	return aggregationConfig, nil
 }
 
+func isConfigFile(name string) bool {
+	return strings.HasSuffix(name, ".yml") || strings.HasSuffix(name, ".yaml")
+}
+
+// 配置中是否启用了热重载 (assist插件的configReload参数)
+func reloadEnabled(config *Config) bool {
+	plugin, ok := config.loadedPlugins["assist"]
+	if !ok {
+		return false
+	}
+	a := plugin.(*plugins.Assist) //Assist指针才实现了PluginConfigNG接口
+	return a.ConfigReload
+}
+
+// 校验合并后的配置: 服务依赖必须指向已存在的服务且不能成环
+func (config *Config) validate() error {
+	var svs []pool.Supervisor
+	for i := range config.Services {
+		if err := config.Services[i].Validate(); err != nil {
+			return errors.New("invalid service " + err.Error())
+		}
+		svs = append(svs, &config.Services[i])
+	}
+	if _, err := pool.DependencyLayers(svs); err != nil {
+		return errors.New("invalid services dependencies: " + err.Error())
+	}
+	return nil
+}
+
 //  load all plugins for current config
 //  读取当前配置文件中的所有插件,并将配置中的参数映射到插件实例 即PluginConfigNG对象
-func (config *Config) loadAllPlugins(fileName string) {
+//  无法加载的插件被跳过，返回第一个错误
+func (config *Config) loadAllPlugins(fileName string) error {
+	var firstErr error
 	for pluginName, description := range config.Plugins {
 		pluginInstance, found := plugins.BuildPlugin(pluginName, fileName)
 		if !found {
 			log.Infoln("Plugin -->", pluginName, "<-- Not Found")
+			if firstErr == nil {
+				firstErr = errors.New("plugin " + pluginName + " not found")
+			}
 			continue
 		}
 
@@ -189,10 +253,14 @@ func (config *Config) loadAllPlugins(fileName string) {
 		err = decoder.Decode(wrap)
 		if err != nil {
 			log.Infoln("Failed load plugin", pluginName, "-", err)
+			if firstErr == nil {
+				firstErr = errors.New("load plugin " + pluginName + ": " + err.Error())
+			}
 			continue
 		}
 		config.loadedPlugins[pluginName] = pluginInstance
 	}
+	return firstErr
 }
 
 //合并配置文件
@@ -1,28 +1,27 @@
 package monexec
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/fsnotify/fsnotify"
-	"github.com/mitchellh/mapstructure"
 	"github.com/reddec/monexec/plugins"
 	"github.com/reddec/monexec/pool"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
-	"gopkg.in/yaml.v2"
-	"io/ioutil"
-	"path"
-	"runtime"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
 )
 
 /*
-TODO 1、只有单一配置文件的情况下才支持热重载，当多配置文件时程序原逻辑需要对配置文件做合并再执行，如果不合并则可能会发生配置文件冲突，此时可能达不到修改配置所期望的需求
-          同时程序此时是基于内存中的合并后的配置去执行的，并不是基于某一配置文件的即无法对下一次的变更做监听
-	   2、已启动服务的配置无法变更（即关键的command,args无法变更），除非停止服务。
-          同时已启动的服务是在goroutine中运行的，如何根据配置的变更定位到执行该服务的goroutine并执行相应的操作
-       3、此热重载并不是简单的根据新配置来重载启动程序，而是需要鉴别出新增服务，被删除的服务。即如何界定何为新增的服务、被删除的服务
-          关键点如何判断配置文件是否变更？
-       4、因为配置文件解析(Unmarshal)就无法做唯一性判断，所以如果想在解析后做唯一性判断，会因为同参数配置导致无法选取具体是存留哪一个配置 --回到问题3
-          假如按label做唯一性判断，即解析出两个相同label的服务，且command和args不同，那具体加载哪一个舍弃哪一个?
-       5、热重载一般为配置形参数的热重载，非执行命令形参数热重载
+TODO 1、热重载一般为配置形参数的热重载，非执行命令形参数热重载
+重载由SIGHUP、控制接口(monexec ctl reload)、REST接口(POST /reload)触发；启用assist.configReload时还会监听文件改动。
+监听启动时指定的所有配置文件和目录(目录中的所有.yml/.yaml文件)，任一文件改动后(短时间内的多次改动只触发一次)
+按启动时相同的逻辑重新读取并合并全部配置，完整校验通过后才作为一个变更集应用，有错误时不做任何变更。
+服务按label（未设置label时按command和args）比对: 新增的服务启动，删除的服务停止，配置有变更的服务重启，其余不变。
+重载时label必须唯一
 */
 
 func init() {
@@ -32,110 +31,247 @@ func init() {
 	})
 }
 
-//初始化Viper实例,并开启新协程监听配置文件重载
-func initViper(location, fileName string) {
+// 热重载使用的配置文件和目录
+var reloadLocations []string
+
+// 文件改动后等待的时间: 编辑器保存文件时会触发多个事件
+const reloadDebounce = 500 * time.Millisecond
+
+//监听配置文件和目录的改动 实现热重载
+func watchConfig(locations []string) {
 	log.Info("--->  配置文件热重载初始化  <---")
-	log.Info("Viper Start Loading Configuration...")
-	viperCfg := viper.New()
-	viperCfg.SetConfigName(fileName)
-	viperCfg.AddConfigPath(location)
-	viperCfg.SetConfigType("yaml")
-	err := viperCfg.ReadInConfig()
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		log.Fatal("Viper Failed to get the Configuration.")
+		log.Errorln("配置文件热重载初始化失败:", err)
+		return
 	}
+	defer watcher.Close()
 
-	go enableDynamicConfig(viperCfg, location, fileName)
-}
-
-//启用Viper监听配置文件改动 实现热重载
-func enableDynamicConfig(viperCfg *viper.Viper, location, file string) {
-	viperCfg.WatchConfig()
+	dirs := make(map[string]bool)  // 监听目录中的所有配置文件
+	files := make(map[string]bool) // 监听单个配置文件
+	for _, location := range locations {
+		location, err := filepath.Abs(location)
+		if err != nil {
+			log.Errorln("配置文件热重载初始化失败:", err)
+			return
+		}
+		dir := location
+		if stat, err := os.Stat(location); err == nil && stat.IsDir() {
+			dirs[location] = true
+		} else {
+			files[location] = true
+			dir = filepath.Dir(location)
+		}
+		//监听文件所在的目录: 编辑器保存时可能会用新文件替换原文件
+		if err := watcher.Add(dir); err != nil {
+			log.Errorln("配置文件热重载初始化失败:", err)
+			return
+		}
+	}
 
-	//TODO bugs: 使用vi、atom、vscode等其他编辑器编辑配置文件时，会触发两次回调
-	viperCfg.OnConfigChange(func(event fsnotify.Event) {
-		gLock.Lock()
-		defer gLock.Unlock()
-		log.Infof("检测到配置文件改变 %s ", event.String())
-		//每次监听配置改动的时候，都需要先判断配置中是否关闭了热重载参数
-		if enable := viperCfg.GetBool("assist.configReload"); enable {
-			var conf = DefaultConfig()
-			fileName := path.Join(location, file)
-			data, err := ioutil.ReadFile(fileName)
-			err = yaml.Unmarshal(data, &conf)
-			if err != nil {
-				log.Infoln("读取最新配置文件出错...", err)
+	//使用vi、atom、vscode等编辑器保存配置文件时会触发多个事件，最后一个事件之后reloadDebounce才重载
+	var debounce <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			name, _ := filepath.Abs(event.Name)
+			if !files[name] && !(dirs[filepath.Dir(name)] && isConfigFile(name)) {
+				continue
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			log.Infof("检测到配置文件改变 %s ", event.String())
+			debounce = time.After(reloadDebounce)
+		case <-debounce:
+			debounce = nil
+			if !reloadOnChange() {
+				log.Infoln("配置文件热重载关闭...")
+				return
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
 				return
 			}
+			log.Errorln("配置文件监听出错:", err)
+		}
+	}
+}
 
-			needStartServ := getNewServices(globalConfig.Services, conf.Services)
-			//启动新服务 不启动新协程
-			startNewService(needStartServ)
+// 重新读取配置并应用. 配置中关闭了热重载参数时返回false
+func reloadOnChange() bool {
+	gLock.Lock()
+	defer gLock.Unlock()
+	if globalPool == nil {
+		log.Infoln("服务尚未启动，忽略配置文件改动")
+		return true
+	}
+	conf, err := parseConfig(true, reloadLocations...)
+	if err != nil {
+		log.Infoln("读取最新配置文件出错...", err)
+		return true
+	}
+	//每次监听配置改动的时候，都需要先判断配置中是否关闭了热重载参数
+	if !reloadEnabled(conf) {
+		return false
+	}
+	if err := applyConfig(conf); err != nil {
+		log.Infoln("应用最新配置出错...", err)
+	}
+	return true
+}
 
-			needLoadPlugins := getNewPlugins(globalConfig, &conf)
-			//加载新插件 不启动新协程
-			loadNewPlugin(fileName, needLoadPlugins)
+// Reload re-reads configuration files and applies changes same way as on file change.
+// Nothing is changed if new configuration is invalid
+func Reload() error {
+	gLock.Lock()
+	defer gLock.Unlock()
+	if globalConfig == nil || globalPool == nil {
+		return errors.New("configuration is not loaded from files")
+	}
+	log.Infoln("手动触发配置重载", reloadLocations)
+	conf, err := parseConfig(true, reloadLocations...)
+	if err != nil {
+		return err
+	}
+	return applyConfig(conf)
+}
 
-			//TODO 是否启用重载插件不同参数
-			//loadPluginsDiff(&conf)
-		} else {
-			pool.HotReloadCloseChan <- true
-			log.Infoln("配置文件热重载关闭...")
-			runtime.Goexit()
-		}
+// 应用合并后的新配置: 插件和服务的变更. 调用方需持有gLock
+func applyConfig(conf *Config) error {
+	diff, err := diffServices(globalConfig.Services, conf.Services)
+	if err != nil {
+		return err
+	}
+	//先应用插件的变更，插件才能收到变更服务的事件
+	//关闭删除的插件、更新变更的插件
+	reloadPlugins(conf)
+	needLoadPlugins := getNewPlugins(globalConfig, conf)
+	//加载新插件 不启动新协程
+	loadNewPlugin(needLoadPlugins, conf)
 
-	})
+	//停止删除的服务、重启变更的服务、启动新服务 不启动新协程
+	applyServicesDiff(diff)
+	//globalConfig中的服务要始终与实际运行状态保持统一，下一次热重载基于它做比较
+	globalConfig.Services = conf.Services
+	return nil
 }
 
-// 获取更改配置后所有需要新启动的服务
-func getNewServices(oldServ, newServ []pool.Executable) []pool.Executable {
-	//TODO old: 如何判断是否为新增服务?实际上同label、command、args的服务可以重复启动 即判逻辑上判断为已经存在的旧服务，但实际可能为新增加的需要启动的同名服务
-	//     new: 只用label来判断，由编写配置文件的人员自行把控，因为存在需要启动 同command和args服务的情况 同时只关心新增服务的情况
-	var needToStart []pool.Executable
-	servMap := make(map[string]pool.Executable)
-	for _, v1 := range oldServ {
-		servMap[v1.Name] = v1
+// 热重载: 服务key -> Pool中运行的Supervisor
+var runningServices = make(map[string]*pool.Executable)
+
+// serviceKey identifies service between reloads: label or command with arguments if label is not set
+func serviceKey(exe *pool.Executable) string {
+	if exe.Name != "" {
+		return exe.Name
 	}
-	for _, v := range newServ {
-		if _, ok := servMap[v.Name]; !ok {
-			needToStart = append(needToStart, v)
+	return strings.Join(append([]string{exe.Command}, exe.Args...), " ")
+}
+
+// 更改配置后服务的变更集
+type servicesDiff struct {
+	added     []pool.Executable
+	removed   []pool.Executable
+	changed   []pool.Executable // 新配置
+	unchanged []pool.Executable
+}
+
+func (diff *servicesDiff) String() string {
+	labels := func(list []pool.Executable) string {
+		var keys []string
+		for i := range list {
+			keys = append(keys, serviceKey(&list[i]))
 		}
+		return "[" + strings.Join(keys, ", ") + "]"
 	}
-	return needToStart
+	return fmt.Sprintf("added %v %v, removed %v %v, changed %v %v, unchanged %v",
+		len(diff.added), labels(diff.added),
+		len(diff.removed), labels(diff.removed),
+		len(diff.changed), labels(diff.changed),
+		len(diff.unchanged))
 }
 
-// 启动新服务
-func startNewService(needStartServ []pool.Executable) {
-	if needStartServ != nil {
-		log.Infof("---> 开始服务热重载...  新增服务数(%v) <---", len(needStartServ))
-		for i := range needStartServ {
-			exec := needStartServ[i]
-			FillDefaultExecutable(&exec)
-
-			log.Infof("正在启动第%v个服务:  >>>%v<<<", i+1, exec.Name)
-			//1、 globalConfig中的服务数要始终与实际运行状态保持统一
-			//2、 因为获取新增服务都是与globalConfig做比较，保证globalConfig中服务数正确才能在下一次热重载时获取到新增服务
-			globalConfig.Services = append(globalConfig.Services, exec)
-			globalPool.Add(&exec)
+// 比对新旧配置中的服务
+func diffServices(oldServ, newServ []pool.Executable) (*servicesDiff, error) {
+	var diff servicesDiff
+	oldMap := make(map[string]pool.Executable)
+	for i := range oldServ {
+		oldMap[serviceKey(&oldServ[i])] = oldServ[i]
+	}
+	newKeys := make(map[string]bool)
+	for i := range newServ {
+		exe := newServ[i]
+		key := serviceKey(&exe)
+		if newKeys[key] {
+			return nil, errors.New("duplicated service " + key + ": labels have to be unique for reload")
+		}
+		newKeys[key] = true
+		old, ok := oldMap[key]
+		switch {
+		case !ok:
+			diff.added = append(diff.added, exe)
+		case !reflect.DeepEqual(old, exe):
+			diff.changed = append(diff.changed, exe)
+		default:
+			diff.unchanged = append(diff.unchanged, exe)
+		}
+	}
+	for key, exe := range oldMap {
+		if !newKeys[key] {
+			diff.removed = append(diff.removed, exe)
+		}
+	}
+	return &diff, nil
+}
 
-			//通过chan传递需要新启动的服务
-			pool.NewServChan <- &exec
+// 应用服务变更集
+func applyServicesDiff(diff *servicesDiff) {
+	log.Infoln("---> 开始服务热重载:", diff, "<---")
+	for i := range diff.removed {
+		key := serviceKey(&diff.removed[i])
+		if sv, ok := runningServices[key]; ok {
+			log.Infof("正在停止被删除的服务:  >>>%v<<<", key)
+			globalPool.Remove(sv)
+			delete(runningServices, key)
+		}
+	}
+	for i := range diff.changed {
+		key := serviceKey(&diff.changed[i])
+		if sv, ok := runningServices[key]; ok {
+			log.Infof("正在停止变更的服务:  >>>%v<<<", key)
+			globalPool.Remove(sv)
+			delete(runningServices, key)
 		}
-	} else {
-		log.Infoln("没有新增服务需要启动...")
+		startService(diff.changed[i])
+	}
+	for i := range diff.added {
+		startService(diff.added[i])
 	}
+	log.Infoln("---> 服务热重载完成:", diff, "<---")
+}
+
+// 启动服务
+func startService(exe pool.Executable) {
+	key := serviceKey(&exe)
+	exec := exe
+	FillDefaultExecutable(&exec)
+	log.Infof("正在启动服务:  >>>%v<<<", exec.Name)
+	globalPool.Add(&exec)
+	runningServices[key] = &exec
+
+	//直接启动，不等待就绪: 持有gLock时不能阻塞。与StartAll启动的服务一样由Pool终止时按依赖关系停止
+	globalPool.StartReplicas(context.Background(), &exec)
 }
 
 //获取更改配置后所有需要新加载的插件
-func getNewPlugins(oldConf, newConf *Config) map[string]interface{} {
-	var needLoadPlugins = make(map[string]interface{})
-	var pluginMap = make(map[string]byte)
+func getNewPlugins(oldConf, newConf *Config) map[string]plugins.PluginConfigNG {
+	var needLoadPlugins = make(map[string]plugins.PluginConfigNG)
 	//旧配置中的插件肯定都是已经加载的插件，所以是与loadedPlugins比较
-	for pNameOld := range oldConf.loadedPlugins {
-		pluginMap[pNameOld] = 1
-	}
-	for pNameNew, plugin := range newConf.Plugins {
-		if _, ok := pluginMap[pNameNew]; !ok {
+	for pNameNew, plugin := range newConf.loadedPlugins {
+		if _, ok := oldConf.loadedPlugins[pNameNew]; !ok {
 			needLoadPlugins[pNameNew] = plugin
 		}
 	}
@@ -143,49 +279,78 @@ func getNewPlugins(oldConf, newConf *Config) map[string]interface{} {
 	return needLoadPlugins
 }
 
-//加载新插件
-func loadNewPlugin(fileName string, needLoadPlugins map[string]interface{}) {
+//加载新插件. 只记录就绪插件的配置来源: 未就绪的插件在下一次重载时重新加载
+func loadNewPlugin(needLoadPlugins map[string]plugins.PluginConfigNG, newConf *Config) {
 	if len(needLoadPlugins) != 0 {
 		log.Infof("---> 开始加载新插件...  新增插件数(%v) <---", len(needLoadPlugins))
 
-		//将插件加载到loadedPlugins映射中
-		var tempConf = DefaultConfig()
-		tempConf.Plugins = needLoadPlugins
-		tempConf.loadAllPlugins(fileName)
-		err := globalConfig.mergePluginsFrom(&tempConf)
-		if err != nil {
-			log.Errorln(err)
-		}
-
-		for pluginName := range needLoadPlugins {
-			if pluginInstance, exist := globalConfig.loadedPlugins[pluginName]; exist {
-				err := pluginInstance.Prepare(*globalCtx, globalPool)
-				if err != nil {
-					log.Infoln("Failed prepare plugin", pluginName, "-", err)
-				} else {
-					log.Infoln("---> 插件", pluginName, "就绪 <---")
-					globalPool.Watch(pluginInstance)
-				}
+		for pluginName, pluginInstance := range needLoadPlugins {
+			//将插件加载到loadedPlugins映射中
+			globalConfig.loadedPlugins[pluginName] = pluginInstance
+			err := pluginInstance.Prepare(*globalCtx, globalPool)
+			if err != nil {
+				log.Infoln("Failed prepare plugin", pluginName, "-", err)
+			} else {
+				log.Infoln("---> 插件", pluginName, "就绪 <---")
+				globalConfig.pluginSources[pluginName] = newConf.pluginSources[pluginName]
 			}
+			globalPool.WatchPlugin(pluginName, pluginInstance, err)
 		}
 	}
 }
 
-//TODO
-//重新加载修改过的插件数据
-func loadPluginsDiff(newConf *Config) {
-	//TODO 如果旧配置中的参数 新配置中不存在，是把此参数做删除处理还是保持原参数不变
-	//     1、如果删除的话，即旧配置完全按新配置覆盖
-	//     2、如果保持不变的话，即分别判断每一项，用新配置中存在的参数项替换旧配置对应的参数项
-	for pluginName, newPluginIns := range newConf.Plugins {
-		switch pluginName {
-		case "assist":
-			var npi plugins.Assist
-			mapstructure.Decode(newPluginIns, &npi)
-			plugins.AssistInfo.Machine = npi.Machine
-			plugins.AssistInfo.Ip = npi.Ip
-			plugins.AssistInfo.ConfigReload = npi.ConfigReload
+//关闭删除的插件，更新配置有变更的插件: 支持plugins.Reconfigurable的插件直接更新配置，
+//其他插件用新实例替换(新实例就绪后才关闭旧实例，失败时保留旧实例).
+//只有变更成功的插件才记录新的配置来源，失败的插件在下一次重载时重试
+func reloadPlugins(newConf *Config) {
+	for pluginName, oldPlugin := range globalConfig.loadedPlugins {
+		newPlugin, ok := newConf.loadedPlugins[pluginName]
+		if !ok {
+			log.Infoln("---> 关闭被删除的插件", pluginName, "<---")
+			closePlugin(pluginName, oldPlugin)
+			delete(globalConfig.loadedPlugins, pluginName)
+			delete(globalConfig.pluginSources, pluginName)
+			continue
+		}
+		if reflect.DeepEqual(globalConfig.pluginSources[pluginName], newConf.pluginSources[pluginName]) {
+			continue
+		}
+		//未就绪的插件没有可更新的状态，直接替换
+		if reconfigurable, ok := oldPlugin.(plugins.Reconfigurable); ok && pluginActive(pluginName) {
+			if err := reconfigurable.Reconfigure(*globalCtx, globalPool, newPlugin); err != nil {
+				log.Errorln("Failed reconfigure plugin", pluginName, "-", err)
+			} else {
+				log.Infoln("---> 插件", pluginName, "配置已更新 <---")
+				globalConfig.pluginSources[pluginName] = newConf.pluginSources[pluginName]
+			}
+			continue
 		}
-		//TODO 其他插件配置修改
+		if err := newPlugin.Prepare(*globalCtx, globalPool); err != nil {
+			log.Errorln("Failed prepare plugin", pluginName, "- old configuration is kept:", err)
+			continue
+		}
+		globalPool.WatchPlugin(pluginName, newPlugin, nil)
+		closePlugin(pluginName, oldPlugin)
+		globalConfig.loadedPlugins[pluginName] = newPlugin
+		globalConfig.pluginSources[pluginName] = newConf.pluginSources[pluginName]
+		log.Infoln("---> 插件", pluginName, "已按新配置重启 <---")
+	}
+}
+
+//插件是否已就绪并接收事件
+func pluginActive(pluginName string) bool {
+	for _, info := range globalPool.Handlers() {
+		if info.Name == pluginName {
+			return info.Active
+		}
+	}
+	return false
+}
+
+//停止插件接收事件并关闭插件
+func closePlugin(pluginName string, plugin plugins.PluginConfigNG) {
+	globalPool.Unwatch(plugin)
+	if err := plugin.Close(); err != nil {
+		log.Errorln("Failed close plugin", pluginName, "-", err)
 	}
 }
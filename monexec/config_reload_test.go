@@ -0,0 +1,132 @@
+package monexec
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddec/monexec/pool"
+)
+
+func TestServiceKey(t *testing.T) {
+	if key := serviceKey(&pool.Executable{Name: "web", Command: "nginx"}); key != "web" {
+		t.Fatalf("expected label as key, got %v", key)
+	}
+	if key := serviceKey(&pool.Executable{Command: "nc", Args: []string{"-l", "9000"}}); key != "nc -l 9000" {
+		t.Fatalf("expected command with args as key, got %v", key)
+	}
+}
+
+func TestDiffServices(t *testing.T) {
+	web := pool.Executable{Name: "web", Command: "nginx"}
+	db := pool.Executable{Name: "db", Command: "postgres"}
+	worker := pool.Executable{Name: "worker", Command: "worker", Args: []string{"-v"}}
+	unnamed := pool.Executable{Command: "nc", Args: []string{"-l", "9000"}}
+
+	webChanged := web
+	webChanged.StopTimeout = 5 * time.Second
+	workerArgs := worker
+	workerArgs.Args = []string{"-vv"}
+	webEnv := web
+	webEnv.Environment = map[string]string{"A": "1"}
+	unnamedArgs := unnamed
+	unnamedArgs.Args = []string{"-l", "9001"}
+
+	keys := func(list []pool.Executable) string {
+		var ans []string
+		for i := range list {
+			ans = append(ans, serviceKey(&list[i]))
+		}
+		sort.Strings(ans)
+		return strings.Join(ans, ",")
+	}
+
+	cases := []struct {
+		name                               string
+		oldServ, newServ                   []pool.Executable
+		added, removed, changed, unchanged string
+		err                                string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:      "same",
+			oldServ:   []pool.Executable{web, db},
+			newServ:   []pool.Executable{db, web},
+			unchanged: "db,web",
+		},
+		{
+			name:    "added and removed",
+			oldServ: []pool.Executable{web, db},
+			newServ: []pool.Executable{web, worker},
+			added:   "worker", removed: "db", unchanged: "web",
+		},
+		{
+			name:    "changed settings",
+			oldServ: []pool.Executable{web, worker, db},
+			newServ: []pool.Executable{webChanged, workerArgs, db},
+			changed: "web,worker", unchanged: "db",
+		},
+		{
+			name:    "changed environment",
+			oldServ: []pool.Executable{web},
+			newServ: []pool.Executable{webEnv},
+			changed: "web",
+		},
+		{
+			name:    "unnamed service is identified by command",
+			oldServ: []pool.Executable{unnamed},
+			newServ: []pool.Executable{unnamedArgs},
+			added:   "nc -l 9001", removed: "nc -l 9000",
+		},
+		{
+			name:    "duplicated label",
+			oldServ: []pool.Executable{web},
+			newServ: []pool.Executable{web, webChanged},
+			err:     "duplicated service web",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := diffServices(tc.oldServ, tc.newServ)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, check := range []struct {
+				kind     string
+				expected string
+				list     []pool.Executable
+			}{
+				{"added", tc.added, diff.added},
+				{"removed", tc.removed, diff.removed},
+				{"changed", tc.changed, diff.changed},
+				{"unchanged", tc.unchanged, diff.unchanged},
+			} {
+				if got := keys(check.list); got != check.expected {
+					t.Errorf("%v: expected [%v], got [%v]", check.kind, check.expected, got)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffServicesChangedUsesNewConfig(t *testing.T) {
+	old := pool.Executable{Name: "web", Command: "nginx"}
+	updated := old
+	updated.Command = "httpd"
+	diff, err := diffServices([]pool.Executable{old}, []pool.Executable{updated})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.changed) != 1 || diff.changed[0].Command != "httpd" {
+		t.Fatalf("expected new configuration in changed services, got %+v", diff.changed)
+	}
+}
@@ -0,0 +1,274 @@
+package monexec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reddec/monexec/pool"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultControlSocket returns default path of unix socket for control API: /run/monexec.sock for root and
+// $XDG_RUNTIME_DIR/monexec.sock for other users. Empty (no socket) if XDG_RUNTIME_DIR is not set
+func DefaultControlSocket() string {
+	if os.Geteuid() == 0 {
+		return "/run/monexec.sock"
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "monexec.sock")
+	}
+	return ""
+}
+
+// InstanceInfo - short description of instance for control API
+type InstanceInfo struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	pool.Status
+}
+
+// SupervisorInfo - supervisor and it's instances for control API
+type SupervisorInfo struct {
+	Label     string         `json:"label"`
+	Instances []InstanceInfo `json:"instances"`
+}
+
+// ControlServer serves runtime control API over unix domain socket. Used by `monexec ctl`
+type ControlServer struct {
+	socket string
+	pool   *pool.Pool
+	ctx    context.Context
+	server *http.Server
+}
+
+// ListenControl creates unix socket and serves control API in background. Stale socket file is removed
+func ListenControl(ctx context.Context, socket string, p *pool.Pool) (*ControlServer, error) {
+	if _, err := os.Stat(socket); err == nil {
+		if conn, err := net.Dial("unix", socket); err == nil {
+			conn.Close()
+			return nil, errors.New("control socket " + socket + " is used by another process")
+		}
+		if err := os.Remove(socket); err != nil {
+			return nil, err
+		}
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	cs := &ControlServer{socket: socket, pool: p, ctx: ctx}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", cs.handleStatus)
+	mux.HandleFunc("/start/", cs.withLabel(http.MethodPost, cs.start))
+	mux.HandleFunc("/stop/", cs.withLabel(http.MethodPost, cs.stop))
+	mux.HandleFunc("/restart/", cs.withLabel(http.MethodPost, cs.restart))
+	mux.HandleFunc("/signal/", cs.withLabel(http.MethodPost, cs.signal))
+	mux.HandleFunc("/logs/", cs.withLabel(http.MethodGet, cs.logs))
+	mux.HandleFunc("/reload", cs.handleReload)
+	cs.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := cs.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Errorln("control socket:", err)
+		}
+	}()
+	log.Infoln("control socket available on", socket)
+	return cs, nil
+}
+
+// Close stops server and removes socket file
+func (cs *ControlServer) Close() error {
+	ctx, closer := context.WithTimeout(context.Background(), 1*time.Second)
+	defer closer()
+	err := cs.server.Shutdown(ctx)
+	os.Remove(cs.socket)
+	return err
+}
+
+func (cs *ControlServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	var ans = make([]SupervisorInfo, 0)
+	index := make(map[string]int)
+	for _, sv := range cs.pool.Supervisors() {
+		label := sv.Config().Name
+		if _, ok := index[label]; !ok {
+			index[label] = len(ans)
+			ans = append(ans, SupervisorInfo{Label: label, Instances: make([]InstanceInfo, 0)})
+		}
+	}
+	for _, in := range cs.pool.Instances() {
+		label := in.Config().Name
+		i, ok := index[label]
+		if !ok {
+			i = len(ans)
+			index[label] = i
+			ans = append(ans, SupervisorInfo{Label: label})
+		}
+		ans[i].Instances = append(ans[i].Instances, InstanceInfo{ID: in.ID(), Label: label, Status: in.Status()})
+	}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+func (cs *ControlServer) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if err := cs.pool.Reload(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, "reloaded")
+}
+
+// withLabel checks method and extracts label from last path segment
+func (cs *ControlServer) withLabel(method string, handler func(w http.ResponseWriter, r *http.Request, label string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		label := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if label == "" {
+			writeError(w, http.StatusBadRequest, errors.New("label required"))
+			return
+		}
+		handler(w, r, label)
+	}
+}
+
+func (cs *ControlServer) supervisor(label string) pool.Supervisor {
+	for _, sv := range cs.pool.Supervisors() {
+		if sv.Config().Name == label {
+			return sv
+		}
+	}
+	return nil
+}
+
+func (cs *ControlServer) instances(label string) []pool.Instance {
+	var ans []pool.Instance
+	for _, in := range cs.pool.Instances() {
+		if in.Config().Name == label || in.ID() == label {
+			ans = append(ans, in)
+		}
+	}
+	return ans
+}
+
+// running instances of service. Finished instances are removed from pool
+func (cs *ControlServer) running(label string) []pool.Instance {
+	var ans []pool.Instance
+	for _, in := range cs.instances(label) {
+		if in.Finished() {
+			cs.pool.Stop(in)
+			continue
+		}
+		ans = append(ans, in)
+	}
+	return ans
+}
+
+func (cs *ControlServer) start(w http.ResponseWriter, r *http.Request, label string) {
+	sv := cs.supervisor(label)
+	if sv == nil {
+		writeError(w, http.StatusNotFound, errors.New("unknown service "+label))
+		return
+	}
+	if len(cs.running(label)) > 0 {
+		writeError(w, http.StatusConflict, errors.New("service "+label+" already running"))
+		return
+	}
+	var ids = make([]string, 0)
+	for _, in := range cs.pool.StartReplicas(cs.ctx, sv) {
+		ids = append(ids, in.ID())
+	}
+	writeJSON(w, http.StatusOK, ids)
+}
+
+func (cs *ControlServer) stop(w http.ResponseWriter, r *http.Request, label string) {
+	instances := cs.running(label)
+	if len(instances) == 0 {
+		writeError(w, http.StatusNotFound, errors.New("service "+label+" is not running"))
+		return
+	}
+	var ids = make([]string, 0)
+	for _, in := range instances {
+		cs.pool.Stop(in)
+		ids = append(ids, in.ID())
+	}
+	writeJSON(w, http.StatusOK, ids)
+}
+
+func (cs *ControlServer) restart(w http.ResponseWriter, r *http.Request, label string) {
+	sv := cs.supervisor(label)
+	if sv == nil {
+		writeError(w, http.StatusNotFound, errors.New("unknown service "+label))
+		return
+	}
+	var ids = make([]string, 0)
+	if len(cs.running(label)) == 0 {
+		for _, in := range cs.pool.StartReplicas(cs.ctx, sv) {
+			ids = append(ids, in.ID())
+		}
+		writeJSON(w, http.StatusOK, ids)
+		return
+	}
+	if err := cs.pool.Restart(label); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	for _, in := range cs.instances(label) {
+		ids = append(ids, in.ID())
+	}
+	writeJSON(w, http.StatusOK, ids)
+}
+
+func (cs *ControlServer) signal(w http.ResponseWriter, r *http.Request, label string) {
+	sig, err := pool.ParseSignal(r.URL.Query().Get("signal"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := cs.pool.Signal(label, sig); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, sig.String())
+}
+
+func (cs *ControlServer) logs(w http.ResponseWriter, r *http.Request, label string) {
+	lines, _ := strconv.Atoi(r.URL.Query().Get("lines"))
+	instances := cs.instances(label)
+	if len(instances) == 0 {
+		writeError(w, http.StatusNotFound, errors.New("service "+label+" has no instances"))
+		return
+	}
+	var ans = make(map[string][]string)
+	for _, in := range instances {
+		ans[in.ID()] = in.Logs(lines)
+	}
+	writeJSON(w, http.StatusOK, ans)
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
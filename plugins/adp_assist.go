@@ -73,7 +73,21 @@ func (a *Assist) MergeFrom(other interface{}) error {
 	return nil
 }
 
+// Reconfigure updates machine info and users shown in Web UI
+func (a *Assist) Reconfigure(ctx context.Context, pl *pool.Pool, other interface{}) error {
+	b := other.(*Assist)
+	a.Machine = b.Machine
+	a.Ip = b.Ip
+	a.ConfigReload = b.ConfigReload
+	a.Users = b.Users
+	AssistInfo = a
+	return nil
+}
+
 func (a *Assist) Close() error {
+	if AssistInfo == a {
+		AssistInfo = nil
+	}
 	return nil
 }
 
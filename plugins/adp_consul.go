@@ -10,6 +10,7 @@ import (
 	"github.com/reddec/monexec/pool"
 	"log"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -38,12 +39,29 @@ func DefaultConsul() ConsulPlugin {
 }
 
 func (p *ConsulPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
-	consulConfig := api.DefaultConfig()
-	consulConfig.Address = p.URL
-	consul, err := api.NewClient(consulConfig)
+	consul, err := p.newClient()
 	if err != nil {
 		return err
 	}
+
+	p.Log = log.New(os.Stderr, "[consul] ", log.LstdFlags)
+	p.stop = make(chan struct{}, 1)
+	p.done = make(chan struct{}, 1)
+	p.matched = make(map[string]struct{})
+	p.registerLabels = p.registrations()
+	p.Client = consul
+
+	go p.checkLoop()
+	return nil
+}
+
+func (p *ConsulPlugin) newClient() (*api.Client, error) {
+	consulConfig := api.DefaultConfig()
+	consulConfig.Address = p.URL
+	return api.NewClient(consulConfig)
+}
+
+func (p *ConsulPlugin) registrations() map[string]consulRegistration {
 	var consulRegs []consulRegistration
 	for _, label := range p.Dynamic {
 		consulRegs = append(consulRegs, consulRegistration{Permanent: false, Label: label})
@@ -56,14 +74,60 @@ func (p *ConsulPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
 	for _, v := range consulRegs {
 		lbs[v.Label] = v
 	}
+	return lbs
+}
 
-	p.Log = log.New(os.Stderr, "[consul] ", log.LstdFlags)
-	p.stop = make(chan struct{}, 1)
-	p.done = make(chan struct{}, 1)
+// Reconfigure applies new address, TTL and registered services. Services that are removed from configuration
+// are deregistered, on change of address all services are deregistered from old agent. Running services are
+// registered again with new settings
+func (p *ConsulPlugin) Reconfigure(ctx context.Context, pl *pool.Pool, a interface{}) error {
+	other := a.(*ConsulPlugin)
+	consul := p.Client
+	if other.URL != p.URL {
+		client, err := other.newClient()
+		if err != nil {
+			return err
+		}
+		consul = client
+	}
+	// stop TTL updates while changing
+	close(p.stop)
+	<-p.done
+
+	p.lock.Lock()
+	registered := p.matched
+	oldLabels := p.registerLabels
+	p.lock.Unlock()
+	newLabels := other.registrations()
+	for label, info := range oldLabels {
+		if _, running := registered[label+":ttl"]; !running && !info.Permanent {
+			continue // not registered
+		}
+		if _, keep := newLabels[label]; keep && other.URL == p.URL {
+			continue
+		}
+		p.deregister(label)
+	}
+
+	p.lock.Lock()
 	p.matched = make(map[string]struct{})
-	p.registerLabels = lbs
+	p.URL = other.URL
+	p.TTL = other.TTL
+	p.AutoDeregistrationTimeout = other.AutoDeregistrationTimeout
+	p.Dynamic = other.Dynamic
+	p.Permanent = other.Permanent
+	p.registerLabels = newLabels
 	p.Client = consul
+	p.stop = make(chan struct{}, 1)
+	p.done = make(chan struct{}, 1)
+	p.lock.Unlock()
 
+	for checkID := range registered {
+		label := strings.TrimSuffix(checkID, ":ttl")
+		if info, ok := p.registerLabels[label]; ok {
+			p.register(label, info)
+		}
+	}
 	go p.checkLoop()
 	return nil
 }
@@ -72,10 +136,17 @@ func (p *ConsulPlugin) OnSpawned(ctx context.Context, sv pool.Instance) {}
 
 func (c *ConsulPlugin) OnStarted(ctx context.Context, sv pool.Instance) {
 	label := sv.Config().Name
+	c.lock.Lock()
 	info, exists := c.registerLabels[label]
+	c.lock.Unlock()
 	if !exists {
 		return
 	}
+	c.register(label, info)
+}
+
+// register service with TTL check in Consul
+func (c *ConsulPlugin) register(label string, info consulRegistration) {
 	dereg := c.AutoDeregistrationTimeout
 	if dereg < c.TTL {
 		dereg = 2 * c.TTL
@@ -119,7 +190,9 @@ func (c *ConsulPlugin) OnStarted(ctx context.Context, sv pool.Instance) {
 
 func (c *ConsulPlugin) OnStopped(ctx context.Context, sv pool.Instance, err error) {
 	label := sv.Config().Name
+	c.lock.Lock()
 	info, exists := c.registerLabels[label]
+	c.lock.Unlock()
 	if !exists {
 		return
 	}
@@ -128,12 +201,17 @@ func (c *ConsulPlugin) OnStopped(ctx context.Context, sv pool.Instance, err erro
 	c.lock.Unlock()
 
 	if !info.Permanent {
-		err = c.Client.Agent().ServiceDeregister(label)
-		if err != nil {
-			c.Log.Println("Can't deregister service", label, "in Consul:", err)
-		} else {
-			c.Log.Println("Service", label, "deregistered in Consul")
-		}
+		c.deregister(label)
+	}
+}
+
+// deregister service in Consul
+func (c *ConsulPlugin) deregister(label string) {
+	err := c.Client.Agent().ServiceDeregister(label)
+	if err != nil {
+		c.Log.Println("Can't deregister service", label, "in Consul:", err)
+	} else {
+		c.Log.Println("Service", label, "deregistered in Consul")
 	}
 }
 
@@ -200,6 +278,9 @@ func (c *ConsulPlugin) updateChecks() {
 }
 
 func (c *ConsulPlugin) Close() error {
+	if c.stop == nil { // not prepared
+		return nil
+	}
 	close(c.stop)
 	<-c.done
 	return nil
@@ -0,0 +1,90 @@
+package plugins
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/reddec/monexec/pool"
+)
+
+// fakeAgent records requests to Consul agent API
+type fakeAgent struct {
+	lock     sync.Mutex
+	requests []string
+}
+
+func (fa *fakeAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fa.lock.Lock()
+	defer fa.lock.Unlock()
+	if !strings.Contains(r.URL.Path, "/check/") {
+		fa.requests = append(fa.requests, r.URL.Path)
+	}
+}
+
+// take recorded requests
+func (fa *fakeAgent) take() string {
+	fa.lock.Lock()
+	defer fa.lock.Unlock()
+	ans := fa.requests
+	fa.requests = nil
+	sort.Strings(ans)
+	return strings.Join(ans, ",")
+}
+
+func TestConsulReconfigure(t *testing.T) {
+	oldAgent, newAgent := &fakeAgent{}, &fakeAgent{}
+	oldServer, newServer := httptest.NewServer(oldAgent), httptest.NewServer(newAgent)
+	defer oldServer.Close()
+	defer newServer.Close()
+
+	p := DefaultConsul()
+	p.URL = oldServer.URL
+	p.Dynamic = []string{"web", "db"}
+	if err := p.Prepare(context.Background(), &pool.Pool{}); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	for label, info := range p.registerLabels {
+		p.register(label, info)
+	}
+	oldAgent.take()
+
+	// service removed from configuration is deregistered
+	other := DefaultConsul()
+	other.URL = oldServer.URL
+	other.Dynamic = []string{"web"}
+	if err := p.Reconfigure(context.Background(), nil, &other); err != nil {
+		t.Fatal(err)
+	}
+	if requests := oldAgent.take(); requests != "/v1/agent/service/deregister/db,/v1/agent/service/register" {
+		t.Fatalf("unexpected requests: %v", requests)
+	}
+
+	// services are moved to new agent
+	other = DefaultConsul()
+	other.URL = newServer.URL
+	other.Dynamic = []string{"web"}
+	other.TTL = time.Minute
+	if err := p.Reconfigure(context.Background(), nil, &other); err != nil {
+		t.Fatal(err)
+	}
+	if requests := oldAgent.take(); requests != "/v1/agent/service/deregister/web" {
+		t.Fatalf("unexpected requests to old agent: %v", requests)
+	}
+	if requests := newAgent.take(); requests != "/v1/agent/service/register" {
+		t.Fatalf("unexpected requests to new agent: %v", requests)
+	}
+}
+
+func TestConsulCloseUnprepared(t *testing.T) {
+	p := DefaultConsul()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -16,6 +16,9 @@ func (p *Critical) OnStarted(ctx context.Context, sv pool.Instance) {}
 func (p *Critical) OnStopped(ctx context.Context, sv pool.Instance, err error) {}
 
 func (p *Critical) OnFinished(ctx context.Context, sv pool.Instance) {
+	if sv.Stopped() { // stopped by request (ctl stop, removed by reload, shutdown), not failed
+		return
+	}
 	terminate := false
 	for _, l := range p.Labels {
 		if l == sv.Supervisor().Config().Name {
@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reddec/monexec/pool"
+)
+
+func TestCriticalIgnoresStopRequest(t *testing.T) {
+	service := func(command string) *pool.Executable {
+		return &pool.Executable{
+			Name:           "db",
+			Command:        command,
+			Args:           []string{"10"},
+			RestartTimeout: 10 * time.Millisecond,
+			StopTimeout:    time.Second,
+		}
+	}
+	isDone := func(pl *pool.Pool, wait time.Duration) bool {
+		select {
+		case <-pl.Done():
+			return true
+		case <-time.After(wait):
+			return false
+		}
+	}
+
+	// service removed by config reload
+	pl := &pool.Pool{}
+	pl.Watch(&Critical{Labels: []string{"db"}})
+	removed := service("sleep")
+	pl.Add(removed)
+	pl.Start(context.Background(), removed)
+	pl.Remove(removed)
+	if isDone(pl, 200*time.Millisecond) {
+		t.Fatal("pool is terminated after removal of critical service")
+	}
+
+	// service finished by itself
+	pl = &pool.Pool{}
+	pl.Watch(&Critical{Labels: []string{"db"}})
+	failed := service("false")
+	pl.Add(failed)
+	pl.Start(context.Background(), failed)
+	if !isDone(pl, 5*time.Second) {
+		t.Fatal("pool is not terminated after failure of critical service")
+	}
+}
@@ -54,7 +54,7 @@ func (e *Email) OnStarted(ctx context.Context, sv pool.Instance) {
 func (e *Email) OnStopped(ctx context.Context, sv pool.Instance, err error) {
 	label := sv.Config().Name
 	if e.servicesSet[label] {
-		content, renderErr := e.renderDefault("stopped", label, label, err, e.log)
+		content, renderErr := e.renderDefault(stopAction(sv, err), label, label, err, e.log)
 		if renderErr != nil {
 			e.log.Println("failed render:", renderErr)
 		} else {
@@ -0,0 +1,197 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"github.com/Masterminds/sprig"
+	"github.com/pkg/errors"
+	"github.com/reddec/monexec/pool"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Lifecycle events
+const (
+	eventSpawned  = "spawned"
+	eventStarted  = "started"
+	eventStopped  = "stopped"
+	eventFinished = "finished"
+)
+
+// Command executed on events of services
+type ExecHook struct {
+	Command  string        `yaml:"command"`  // executable
+	Args     []string      `yaml:"args"`     // arguments, templates with same params as in notifications
+	Services []string      `yaml:"services"` // services that trigger command. Empty means all
+	Events   []string      `yaml:"events"`   // spawned, started, stopped, finished. Empty means all
+	Timeout  time.Duration `yaml:"timeout"`  // limit of execution time. Default 20s
+	WorkDir  string        `yaml:"workdir"`  // working directory. Default - configuration directory
+
+	servicesSet map[string]bool
+	eventsSet   map[string]bool
+	args        []*template.Template
+}
+
+type Exec struct {
+	Hooks   []*ExecHook `mapstructure:"<ITEMS>"`
+	log     *log.Logger
+	workDir string
+}
+
+func (p *Exec) OnSpawned(ctx context.Context, sv pool.Instance) {
+	p.trigger(eventSpawned, eventSpawned, sv, nil)
+}
+
+func (p *Exec) OnStarted(ctx context.Context, sv pool.Instance) {
+	p.trigger(eventStarted, eventStarted, sv, nil)
+}
+
+func (p *Exec) OnStopped(ctx context.Context, sv pool.Instance, err error) {
+	p.trigger(eventStopped, stopAction(sv, err), sv, err)
+}
+
+func (p *Exec) OnFinished(ctx context.Context, sv pool.Instance) {
+	p.trigger(eventFinished, eventFinished, sv, nil)
+}
+
+// trigger runs in background all hooks matched by event and service
+func (p *Exec) trigger(event, action string, sv pool.Instance, err error) {
+	label := sv.Config().Name
+	status := sv.Status()
+	hostname, _ := os.Hostname()
+	params := map[string]interface{}{
+		"id":       sv.ID(),
+		"label":    label,
+		"error":    err,
+		"action":   action,
+		"event":    event,
+		"hostname": hostname,
+		"time":     time.Now().String(),
+		"status":   status,
+	}
+	env := append(os.Environ(),
+		"MONEXEC_EVENT="+event,
+		"MONEXEC_ACTION="+action,
+		"MONEXEC_LABEL="+label,
+		"MONEXEC_INSTANCE_ID="+sv.ID(),
+		"MONEXEC_HOSTNAME="+hostname,
+		"MONEXEC_RESTARTS="+strconv.Itoa(status.Restarts),
+	)
+	if status.PID != 0 {
+		env = append(env, "MONEXEC_PID="+strconv.Itoa(status.PID))
+	}
+	if status.LastExitCode != nil {
+		env = append(env, "MONEXEC_EXIT_CODE="+strconv.Itoa(*status.LastExitCode))
+	}
+	if status.LastSignal != "" {
+		env = append(env, "MONEXEC_SIGNAL="+status.LastSignal)
+	}
+	if err != nil {
+		env = append(env, "MONEXEC_ERROR="+err.Error())
+	}
+	for _, hook := range p.Hooks {
+		if hook.matches(event, label) {
+			go hook.run(params, env, p.log)
+		}
+	}
+}
+
+func (h *ExecHook) matches(event, label string) bool {
+	return (len(h.servicesSet) == 0 || h.servicesSet[label]) && (len(h.eventsSet) == 0 || h.eventsSet[event])
+}
+
+func (h *ExecHook) run(params map[string]interface{}, env []string, logger *log.Logger) {
+	var args []string
+	for _, tpl := range h.args {
+		arg := &bytes.Buffer{}
+		if err := tpl.Execute(arg, params); err != nil {
+			logger.Println("failed render argument of", h.Command, ":", err)
+			return
+		}
+		args = append(args, arg.String())
+	}
+	ctx, closer := context.WithTimeout(context.Background(), h.Timeout)
+	defer closer()
+	cmd := exec.CommandContext(ctx, h.Command, args...)
+	cmd.Dir = h.WorkDir
+	cmd.Env = env
+	output, err := cmd.CombinedOutput()
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if line != "" {
+			logger.Println(h.Command, "|", line)
+		}
+	}
+	if err != nil {
+		logger.Println("command", h.Command, "failed:", err)
+	}
+}
+
+func (h *ExecHook) prepare(workDir string) error {
+	if h.Command == "" {
+		return errors.New("command not set")
+	}
+	for _, event := range h.Events {
+		switch event {
+		case eventSpawned, eventStarted, eventStopped, eventFinished:
+		default:
+			return errors.Errorf("unknown event %v", event)
+		}
+	}
+	h.args = nil
+	for _, arg := range h.Args {
+		tpl, err := template.New("").Funcs(sprig.TxtFuncMap()).Parse(arg)
+		if err != nil {
+			return errors.Wrapf(err, "parse argument %v", arg)
+		}
+		h.args = append(h.args, tpl)
+	}
+	h.servicesSet = makeSet(h.Services)
+	h.eventsSet = makeSet(h.Events)
+	if h.Timeout == 0 {
+		h.Timeout = 20 * time.Second
+	}
+	if h.WorkDir == "" {
+		h.WorkDir = workDir
+	} else {
+		h.WorkDir = realPath(h.WorkDir, workDir)
+	}
+	return nil
+}
+
+func (p *Exec) Prepare(ctx context.Context, pl *pool.Pool) error {
+	p.log = log.New(os.Stderr, "[exec] ", log.LstdFlags)
+	for _, hook := range p.Hooks {
+		if err := hook.prepare(p.workDir); err != nil {
+			return errors.Wrapf(err, "exec hook %v", hook.Command)
+		}
+	}
+	return nil
+}
+
+func (p *Exec) MergeFrom(other interface{}) error {
+	b := other.(*Exec)
+	for _, hook := range b.Hooks {
+		// hooks from other file are relative to it's directory
+		if hook.WorkDir == "" {
+			hook.WorkDir = b.workDir
+		} else {
+			hook.WorkDir = realPath(hook.WorkDir, b.workDir)
+		}
+	}
+	p.Hooks = append(p.Hooks, b.Hooks...)
+	return nil
+}
+
+func (p *Exec) Close() error { return nil }
+
+func init() {
+	registerPlugin("exec", func(file string) PluginConfigNG {
+		return &Exec{workDir: filepath.Dir(file)}
+	})
+}
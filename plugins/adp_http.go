@@ -81,7 +81,7 @@ func (c *Http) OnStarted(ctx context.Context, sv pool.Instance) {
 func (c *Http) OnStopped(ctx context.Context, sv pool.Instance, err error) {
 	label := sv.Config().Name
 	if c.servicesSet[label] {
-		content, params, renderErr := c.renderDefaultParams("stopped", label, label, err, c.log)
+		content, params, renderErr := c.renderDefaultParams(stopAction(sv, err), label, label, err, c.log)
 		if renderErr != nil {
 			c.log.Println("failed render:", renderErr)
 		} else {
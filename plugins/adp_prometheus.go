@@ -0,0 +1,287 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/reddec/monexec/pool"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const prometheusStartupCheck = 1 * time.Second
+
+// Exporter of supervisor metrics in Prometheus text format
+type PrometheusPlugin struct {
+	Listen string `yaml:"listen"` // Address of HTTP server. Default localhost:9901
+	Path   string `yaml:"path"`   // Path of metrics. Default /metrics
+
+	pool     *pool.Pool
+	server   *http.Server
+	mux      atomic.Value // http.Handler: replaced on change of path without restart of server
+	lock     sync.Mutex
+	events   map[string]map[string]uint64 // label -> event -> count
+	restarts map[string]int               // restarts of finished instances by label
+	finished map[pool.Instance]bool       // finished instances that are still in pool: already counted in restarts
+	spawned  map[string]time.Time         // last spawn by label
+	exitCode map[string]int               // last exit code by label
+}
+
+func (p *PrometheusPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
+	p.pool = pl
+	p.mux.Store(p.newMux())
+	return p.listen()
+}
+
+// newMux serves metrics on current path
+func (p *PrometheusPlugin) newMux() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(p.Path, p)
+	return mux
+}
+
+// listen starts new HTTP server with current mux
+func (p *PrometheusPlugin) listen() error {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mux.Load().(http.Handler).ServeHTTP(w, r)
+	})
+	p.server = &http.Server{Addr: p.Listen, Handler: handler}
+	fmt.Println("prometheus metrics will be available on", p.Listen+p.Path)
+	start := make(chan error, 1)
+	go func() {
+		start <- p.server.ListenAndServe()
+	}()
+	select {
+	case err := <-start:
+		return err
+	case <-time.After(prometheusStartupCheck):
+		return nil
+	}
+}
+
+func (p *PrometheusPlugin) OnSpawned(ctx context.Context, sv pool.Instance) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	label := sv.Config().Name
+	p.spawned[label] = time.Now()
+	p.countEvent(label, "spawned")
+}
+
+func (p *PrometheusPlugin) OnStarted(ctx context.Context, sv pool.Instance) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.countEvent(sv.Config().Name, "started")
+}
+
+func (p *PrometheusPlugin) OnStopped(ctx context.Context, sv pool.Instance, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	label := sv.Config().Name
+	if code := sv.Status().LastExitCode; code != nil {
+		p.exitCode[label] = *code
+	}
+	p.countEvent(label, "stopped")
+}
+
+func (p *PrometheusPlugin) OnFinished(ctx context.Context, sv pool.Instance) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	label := sv.Config().Name
+	// keep counter monotonic after instance removal
+	p.restarts[label] += sv.Status().Restarts
+	p.finished[sv] = true
+	p.countEvent(label, "finished")
+}
+
+// countEvent without lock
+func (p *PrometheusPlugin) countEvent(label, event string) {
+	byEvent, ok := p.events[label]
+	if !ok {
+		byEvent = make(map[string]uint64)
+		p.events[label] = byEvent
+	}
+	byEvent[event]++
+}
+
+// ServeHTTP writes metrics in Prometheus text exposition format
+func (p *PrometheusPlugin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(p.metrics())
+}
+
+type metricSample struct {
+	labels string
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	samples []metricSample
+}
+
+func (mf *metricFamily) add(value float64, labels ...string) {
+	var parts []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		parts = append(parts, labels[i]+"=\""+escapeLabel(labels[i+1])+"\"")
+	}
+	mf.samples = append(mf.samples, metricSample{labels: strings.Join(parts, ","), value: value})
+}
+
+func (p *PrometheusPlugin) metrics() []byte {
+	var (
+		up        = &metricFamily{name: "monexec_up", help: "Process of instance is running", kind: "gauge"}
+		running   = &metricFamily{name: "monexec_running", help: "Number of running processes of service", kind: "gauge"}
+		uptime    = &metricFamily{name: "monexec_uptime_seconds", help: "Uptime of current process of instance", kind: "gauge"}
+		restarts  = &metricFamily{name: "monexec_restarts_total", help: "Restarts of service processes", kind: "counter"}
+		exitCode  = &metricFamily{name: "monexec_last_exit_code", help: "Exit code of last finished process of service (-1 if killed by signal)", kind: "gauge"}
+		spawned   = &metricFamily{name: "monexec_spawn_timestamp_seconds", help: "Time of last spawn of service instance", kind: "gauge"}
+		events    = &metricFamily{name: "monexec_events_total", help: "Events of service instances: spawned, started, stopped, finished", kind: "counter"}
+		families  = []*metricFamily{up, running, uptime, restarts, exitCode, spawned, events}
+		runCount  = make(map[string]int)
+		restartBy = make(map[string]int)
+	)
+	var instances []pool.Instance
+	if p.pool != nil {
+		for _, sv := range p.pool.Supervisors() {
+			runCount[sv.Config().Name] = 0
+		}
+		instances = p.pool.Instances()
+	}
+
+	p.lock.Lock()
+	inPool := make(map[pool.Instance]bool)
+	for _, in := range instances {
+		inPool[in] = true
+		label := in.Config().Name
+		status := in.Status()
+		isUp := 0.0
+		if status.PID != 0 {
+			isUp = 1
+			runCount[label]++
+		}
+		up.add(isUp, "service", label, "id", in.ID())
+		uptime.add(status.Uptime.Seconds(), "service", label, "id", in.ID())
+		if !p.finished[in] {
+			restartBy[label] += status.Restarts
+		}
+	}
+	for in := range p.finished {
+		if !inPool[in] {
+			delete(p.finished, in)
+		}
+	}
+	for label, count := range p.restarts {
+		restartBy[label] += count
+	}
+	for label, code := range p.exitCode {
+		exitCode.add(float64(code), "service", label)
+	}
+	for label, t := range p.spawned {
+		spawned.add(float64(t.UnixNano())/1e9, "service", label)
+	}
+	for label, byEvent := range p.events {
+		for event, count := range byEvent {
+			events.add(float64(count), "service", label, "event", event)
+		}
+	}
+	p.lock.Unlock()
+
+	for label, count := range runCount {
+		running.add(float64(count), "service", label)
+	}
+	for label, count := range restartBy {
+		restarts.add(float64(count), "service", label)
+	}
+
+	var out bytes.Buffer
+	for _, mf := range families {
+		sort.Slice(mf.samples, func(i, j int) bool { return mf.samples[i].labels < mf.samples[j].labels })
+		fmt.Fprintf(&out, "# HELP %s %s\n# TYPE %s %s\n", mf.name, mf.help, mf.name, mf.kind)
+		for _, s := range mf.samples {
+			fmt.Fprintf(&out, "%s{%s} %s\n", mf.name, s.labels, strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+	return out.Bytes()
+}
+
+func escapeLabel(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	return strings.Replace(value, `"`, `\"`, -1)
+}
+
+func (p *PrometheusPlugin) MergeFrom(o interface{}) error {
+	def := defaultPrometheusPlugin()
+	other := o.(*PrometheusPlugin)
+	if p.Listen == def.Listen {
+		p.Listen = other.Listen
+	} else if other.Listen != def.Listen && other.Listen != p.Listen {
+		return errors.Errorf("unmatched Prometheus listen address %v != %v", p.Listen, other.Listen)
+	}
+	if p.Path == def.Path {
+		p.Path = other.Path
+	} else if other.Path != def.Path && other.Path != p.Path {
+		return errors.Errorf("unmatched Prometheus metrics path %v != %v", p.Path, other.Path)
+	}
+	return nil
+}
+
+// Reconfigure applies new path to running server and moves server to new listen address. Server on new address
+// is started before old one is shut down: old configuration is kept if new server can't be started. Counters are kept
+func (p *PrometheusPlugin) Reconfigure(ctx context.Context, pl *pool.Pool, o interface{}) error {
+	other := o.(*PrometheusPlugin)
+	if p.Listen == other.Listen && p.Path == other.Path {
+		return nil
+	}
+	oldServer, oldListen, oldPath, oldMux := p.server, p.Listen, p.Path, p.mux.Load()
+	p.Path = other.Path
+	p.mux.Store(p.newMux())
+	if p.Listen == other.Listen {
+		return nil
+	}
+	p.Listen = other.Listen
+	if err := p.listen(); err != nil {
+		p.server, p.Listen, p.Path = oldServer, oldListen, oldPath
+		p.mux.Store(oldMux)
+		return err
+	}
+	if oldServer != nil {
+		go shutdownServer(oldServer)
+	}
+	return nil
+}
+
+func (p *PrometheusPlugin) Close() error {
+	if p.server == nil {
+		return nil
+	}
+	ctx, closer := context.WithTimeout(context.Background(), 1*time.Second)
+	defer closer()
+	return p.server.Shutdown(ctx)
+}
+
+func defaultPrometheusPlugin() *PrometheusPlugin {
+	return &PrometheusPlugin{
+		Listen:   "localhost:9901",
+		Path:     "/metrics",
+		events:   make(map[string]map[string]uint64),
+		restarts: make(map[string]int),
+		finished: make(map[pool.Instance]bool),
+		spawned:  make(map[string]time.Time),
+		exitCode: make(map[string]int),
+	}
+}
+
+func init() {
+	registerPlugin("prometheus", func(file string) PluginConfigNG {
+		return defaultPrometheusPlugin()
+	})
+}
@@ -0,0 +1,165 @@
+package plugins
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/reddec/monexec/pool"
+)
+
+// scrape metrics by in-process HTTP client
+func scrape(t *testing.T, p *PrometheusPlugin) string {
+	server := httptest.NewServer(p)
+	defer server.Close()
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %v", ct)
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func expectMetric(t *testing.T, metrics, line string) {
+	t.Helper()
+	for _, l := range strings.Split(metrics, "\n") {
+		if l == line {
+			return
+		}
+	}
+	t.Fatalf("metric %q not found in:\n%v", line, metrics)
+}
+
+func TestPrometheusServeHTTP(t *testing.T) {
+	pl := &pool.Pool{}
+	defer pl.Terminate()
+	p := defaultPrometheusPlugin()
+	p.pool = pl
+	pl.Watch(p)
+
+	exe := &pool.Executable{
+		Name:           "job",
+		Command:        "false",
+		Restart:        2,
+		RestartTimeout: 10 * time.Millisecond,
+		StopTimeout:    time.Second,
+	}
+	pl.Add(exe)
+	in := pl.Start(context.Background(), exe)
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		p.lock.Lock()
+		finished := p.finished[in]
+		p.lock.Unlock()
+		if finished {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("instance is not finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if restarts := in.Status().Restarts; restarts != 2 {
+		t.Fatalf("expected 2 restarts, got %v", restarts)
+	}
+
+	// finished instance is still in pool: restarts are counted once
+	metrics := scrape(t, p)
+	expectMetric(t, metrics, `monexec_restarts_total{service="job"} 2`)
+	expectMetric(t, metrics, `monexec_up{service="job",id="job-0"} 0`)
+	expectMetric(t, metrics, `monexec_running{service="job"} 0`)
+	expectMetric(t, metrics, `monexec_last_exit_code{service="job"} 1`)
+	expectMetric(t, metrics, `monexec_events_total{service="job",event="spawned"} 1`)
+	expectMetric(t, metrics, `monexec_events_total{service="job",event="stopped"} 3`)
+	expectMetric(t, metrics, `monexec_events_total{service="job",event="finished"} 1`)
+	expectMetric(t, metrics, "# TYPE monexec_restarts_total counter")
+
+	// counter is kept after instance removal
+	pl.Stop(in)
+	metrics = scrape(t, p)
+	expectMetric(t, metrics, `monexec_restarts_total{service="job"} 2`)
+	if strings.Contains(metrics, `id="job-0"`) {
+		t.Fatalf("removed instance is exported:\n%v", metrics)
+	}
+}
+
+// free local address for HTTP server
+func freeAddress(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestPrometheusReconfigure(t *testing.T) {
+	pl := &pool.Pool{}
+	p := defaultPrometheusPlugin()
+	p.Listen = freeAddress(t)
+	if err := p.Prepare(context.Background(), pl); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	get := func(url string) int {
+		res, err := http.Get(url)
+		if err != nil {
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	// new address is busy: old server and configuration are kept
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	oldListen := p.Listen
+	other := defaultPrometheusPlugin()
+	other.Listen = busy.Addr().String()
+	other.Path = "/stats"
+	if err := p.Reconfigure(context.Background(), pl, other); err == nil {
+		t.Fatal("expected bind error")
+	}
+	if p.Listen != oldListen || p.Path != "/metrics" {
+		t.Fatalf("configuration is changed to %v%v", p.Listen, p.Path)
+	}
+	if code := get("http://" + oldListen + "/metrics"); code != http.StatusOK {
+		t.Fatalf("old server is not available: %v", code)
+	}
+
+	// path is changed on running server
+	other = defaultPrometheusPlugin()
+	other.Listen = oldListen
+	other.Path = "/stats"
+	if err := p.Reconfigure(context.Background(), pl, other); err != nil {
+		t.Fatal(err)
+	}
+	if code := get("http://" + oldListen + "/stats"); code != http.StatusOK {
+		t.Fatalf("metrics are not available on new path: %v", code)
+	}
+	if code := get("http://" + oldListen + "/metrics"); code != http.StatusNotFound {
+		t.Fatalf("metrics are still available on old path: %v", code)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if v := escapeLabel("a\\b\"c\nd"); v != `a\\b\"c\nd` {
+		t.Fatalf("unexpected escaped value %v", v)
+	}
+}
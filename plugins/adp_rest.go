@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,6 +25,7 @@ type RestPlugin struct {
 	Listen string `yaml:"listen"`
 	CORS   bool   `yaml:"cors"`
 	server *http.Server
+	router atomic.Value // http.Handler: replaced on reconfiguration without restart of server
 }
 
 // 嵌入普通的静态资源
@@ -46,7 +48,12 @@ func (w *StaticResource) Open(name string) (fs.File, error) {
 }
 
 func (p *RestPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
+	p.router.Store(p.newRouter(ctx, pl))
+	return p.listen()
+}
 
+// newRouter creates handlers of REST API and Web UI
+func (p *RestPlugin) newRouter(ctx context.Context, pl *pool.Pool) http.Handler {
 	//是否启用production模式
 	gin.SetMode(gin.ReleaseMode)
 
@@ -120,33 +127,67 @@ func (p *RestPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
 		}
 		gctx.AbortWithStatus(http.StatusNotFound)
 	})
+	router.POST("/supervisor/:name/restart", func(gctx *gin.Context) {
+		if err := pl.Restart(gctx.Param("name")); err != nil {
+			gctx.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		gctx.AbortWithStatus(http.StatusOK)
+	})
+	router.POST("/supervisor/:name/signal/:signal", func(gctx *gin.Context) {
+		sig, err := pool.ParseSignal(gctx.Param("signal"))
+		if err != nil {
+			gctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		if err := pl.Signal(gctx.Param("name"), sig); err != nil {
+			gctx.AbortWithError(http.StatusNotFound, err)
+			return
+		}
+		gctx.AbortWithStatus(http.StatusOK)
+	})
+	router.GET("/plugins", func(gctx *gin.Context) {
+		gctx.JSON(http.StatusOK, pl.Handlers())
+	})
+	router.POST("/reload", func(gctx *gin.Context) {
+		if err := pl.Reload(); err == pool.ErrReloadUnsupported {
+			gctx.AbortWithError(http.StatusNotImplemented, err)
+			return
+		} else if err != nil {
+			gctx.AbortWithError(http.StatusBadRequest, err)
+			return
+		}
+		gctx.AbortWithStatus(http.StatusOK)
+	})
 	router.GET("/instances", func(gctx *gin.Context) {
-		var names = make([]string, 0)
+		var ids = make([]string, 0)
 		for _, sv := range pl.Instances() {
-			names = append(names, sv.Config().Name)
+			ids = append(ids, sv.ID())
 		}
-		gctx.JSON(http.StatusOK, names)
+		gctx.JSON(http.StatusOK, ids)
 	})
 
 	router.GET("/instance/:name", func(gctx *gin.Context) {
-		name := gctx.Param("name")
-		for _, sv := range pl.Instances() {
-			if sv.Config().Name == name {
-				gctx.JSON(http.StatusOK, sv)
-				return
-			}
+		if sv := findInstance(pl, gctx.Param("name")); sv != nil {
+			gctx.JSON(http.StatusOK, sv)
+			return
+		}
+		gctx.AbortWithStatus(http.StatusNotFound)
+	})
+
+	router.GET("/instance/:name/stats", func(gctx *gin.Context) {
+		if sv := findInstance(pl, gctx.Param("name")); sv != nil {
+			gctx.JSON(http.StatusOK, sv.Stats())
+			return
 		}
 		gctx.AbortWithStatus(http.StatusNotFound)
 	})
 
 	router.POST("/instance/:name", func(gctx *gin.Context) {
-		name := gctx.Param("name")
-		for _, sv := range pl.Instances() {
-			if sv.Config().Name == name {
-				pl.Stop(sv)
-				gctx.AbortWithStatus(http.StatusCreated)
-				return
-			}
+		if sv := findInstance(pl, gctx.Param("name")); sv != nil {
+			pl.Stop(sv)
+			gctx.AbortWithStatus(http.StatusCreated)
+			return
 		}
 		gctx.AbortWithStatus(http.StatusNotFound)
 	})
@@ -155,6 +196,7 @@ func (p *RestPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
 	router.GET("/info", func(gctx *gin.Context) {
 		if AssistInfo == nil {
 			gctx.AbortWithStatus(http.StatusBadGateway)
+			return
 		}
 		info := Assist{
 			Machine: AssistInfo.Machine,
@@ -167,6 +209,7 @@ func (p *RestPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
 	router.POST("/login", func(gctx *gin.Context) {
 		if AssistInfo == nil || len(AssistInfo.Users) == 0 {
 			gctx.AbortWithStatus(http.StatusBadGateway)
+			return
 		}
 		name := gctx.PostForm("name")
 		password := gctx.PostForm("password")
@@ -183,7 +226,15 @@ func (p *RestPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
 		gctx.JSON(http.StatusOK, info)
 	})
 
-	p.server = &http.Server{Addr: p.Listen, Handler: router}
+	return router
+}
+
+// listen starts new HTTP server with current router
+func (p *RestPlugin) listen() error {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.router.Load().(http.Handler).ServeHTTP(w, r)
+	})
+	p.server = &http.Server{Addr: p.Listen, Handler: handler}
 	fmt.Println("rest interface will be available on", p.Listen)
 	start := make(chan error, 1)
 	go func() {
@@ -197,6 +248,19 @@ func (p *RestPlugin) Prepare(ctx context.Context, pl *pool.Pool) error {
 	}
 }
 
+// find instance by ID or (for compatibility) by label
+func findInstance(pl *pool.Pool, id string) pool.Instance {
+	if in := pl.Instance(id); in != nil {
+		return in
+	}
+	for _, in := range pl.Instances() {
+		if in.Config().Name == id {
+			return in
+		}
+	}
+	return nil
+}
+
 func (p *RestPlugin) OnSpawned(ctx context.Context, sv pool.Instance) {}
 
 func (p *RestPlugin) OnStarted(ctx context.Context, sv pool.Instance) {}
@@ -216,10 +280,44 @@ func (p *RestPlugin) MergeFrom(o interface{}) error {
 	return nil
 }
 
+// Reconfigure applies CORS to running server and moves server to new listen address. Server on new address
+// is started before old one is shut down: reconfiguration may be triggered by request to old server (POST /reload)
+func (p *RestPlugin) Reconfigure(ctx context.Context, pl *pool.Pool, o interface{}) error {
+	other := o.(*RestPlugin)
+	if p.CORS != other.CORS {
+		p.CORS = other.CORS
+		p.router.Store(p.newRouter(ctx, pl))
+	}
+	if p.Listen == other.Listen {
+		return nil
+	}
+	oldServer, oldListen := p.server, p.Listen
+	p.Listen = other.Listen
+	if err := p.listen(); err != nil {
+		p.server, p.Listen = oldServer, oldListen
+		return err
+	}
+	if oldServer != nil {
+		go shutdownServer(oldServer)
+	}
+	return nil
+}
+
 func (p *RestPlugin) Close() error {
+	if p.server == nil {
+		return nil
+	}
+	shutdownServer(p.server)
+	return nil
+}
+
+// shutdownServer gracefully stops server. Connections that are still active after timeout are closed
+func shutdownServer(server *http.Server) {
 	ctx, closer := context.WithTimeout(context.Background(), 1*time.Second)
 	defer closer()
-	return p.server.Shutdown(ctx)
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+	}
 }
 
 func defaultRestPlugin() *RestPlugin {
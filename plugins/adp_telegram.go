@@ -53,7 +53,7 @@ func (c *Telegram) OnStarted(ctx context.Context, sv pool.Instance) {
 
 func (c *Telegram) OnStopped(ctx context.Context, sv pool.Instance, err error) {
 	if c.servicesSet[sv.Config().Name] {
-		content, renderErr := c.renderDefault("stopped", string(sv.Config().Name), sv.Config().Name, err, c.logger)
+		content, renderErr := c.renderDefault(stopAction(sv, err), string(sv.Config().Name), sv.Config().Name, err, c.logger)
 		if renderErr != nil {
 			c.logger.Println("failed render:", renderErr)
 		} else {
@@ -37,3 +37,10 @@ type PluginConfigNG interface {
 	// Prepare internal state
 	Prepare(ctx context.Context, pl *pool.Pool) error
 }
+
+// Optional interface of plugin that applies changed configuration on reload without restart.
+// Plugins without it are replaced by new instance: new one is prepared, old one is closed
+type Reconfigurable interface {
+	// Apply configuration from other (not prepared) instance with same type. Old configuration is kept on error
+	Reconfigure(ctx context.Context, pl *pool.Pool, other interface{}) error
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	"time"
 	"os"
+	"github.com/reddec/monexec/pool"
 )
 
 type withTemplate struct {
@@ -71,6 +72,18 @@ func (wt *withTemplate) MergeFrom(other *withTemplate) error {
 	return nil
 }
 
+// stopAction returns name of action for stopped instance: flapping (crash loop), overlimit (resource threshold
+// exceeded) or just stopped
+func stopAction(sv pool.Instance, err error) string {
+	if sv.State() == pool.StateCrashLoop {
+		return "flapping"
+	}
+	if _, ok := err.(*pool.LimitError); ok {
+		return "overlimit"
+	}
+	return "stopped"
+}
+
 func unique(names []string) []string {
 	var hash = make(map[string]struct{})
 	for _, name := range names {
@@ -0,0 +1,138 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// environment variable with process attributes for shim (see ShimMain)
+const shimEnv = "MONEXEC_SHIM"
+
+// ErrShimNotInstalled returned by start of service with process attributes if ShimMain is not called by program
+var ErrShimNotInstalled = errors.New("process attributes and isolation require pool.ShimMain() at the beginning of main")
+
+// shimSpec - attributes applied by shim to itself before exec of service command
+type shimSpec struct {
+	Path        string        `json:"path"`
+	Rlimits     []shimRlimit  `json:"rlimits,omitempty"`
+	Nice        *int          `json:"nice,omitempty"`
+	OOMScoreAdj *int          `json:"oom_score_adj,omitempty"`
+	Umask       *int          `json:"umask,omitempty"`
+	NoNewPrivs  bool          `json:"no_new_privs,omitempty"`
+	Credential  *shimIdentity `json:"credential,omitempty"`
+	Pdeathsig   int           `json:"pdeathsig,omitempty"`
+	Mounts      *shimMounts   `json:"mounts,omitempty"`
+}
+
+type shimRlimit struct {
+	Resource int    `json:"resource"`
+	Cur      uint64 `json:"cur"`
+	Max      uint64 `json:"max"`
+}
+
+type shimIdentity struct {
+	Uid    uint32   `json:"uid"`
+	Gid    uint32   `json:"gid"`
+	Groups []uint32 `json:"groups"`
+}
+
+// needShim returns true if process attributes can't be applied by SysProcAttr
+func (exe *Executable) needShim() bool {
+	return len(exe.Rlimits) > 0 || exe.Nice != nil || exe.OOMScoreAdj != nil || exe.Umask != "" || exe.NoNewPrivs ||
+		(exe.Isolation != nil && exe.Isolation.needMounts())
+}
+
+func (exe *Executable) validateAttrs() error {
+	for name, value := range exe.Rlimits {
+		if _, ok := rlimitResources[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("rlimits: unknown limit %v", name)
+		}
+		if _, _, err := parseRlimit(value); err != nil {
+			return fmt.Errorf("rlimits: %v: %v", name, err)
+		}
+	}
+	if exe.Nice != nil && (*exe.Nice < -20 || *exe.Nice > 19) {
+		return errors.New("nice should be in range -20..19")
+	}
+	if exe.OOMScoreAdj != nil && (*exe.OOMScoreAdj < -1000 || *exe.OOMScoreAdj > 1000) {
+		return errors.New("oom_score_adj should be in range -1000..1000")
+	}
+	if exe.Umask != "" {
+		if _, err := parseUmask(exe.Umask); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shimSpec collects attributes for shim
+func (exe *Executable) shimSpec(path string, id *identity) (*shimSpec, error) {
+	spec := &shimSpec{Path: path, Nice: exe.Nice, OOMScoreAdj: exe.OOMScoreAdj, NoNewPrivs: exe.NoNewPrivs}
+	for name, value := range exe.Rlimits {
+		cur, max, err := parseRlimit(value)
+		if err != nil {
+			return nil, err
+		}
+		spec.Rlimits = append(spec.Rlimits, shimRlimit{Resource: rlimitResources[strings.ToLower(name)], Cur: cur, Max: max})
+	}
+	if exe.Umask != "" {
+		mask, err := parseUmask(exe.Umask)
+		if err != nil {
+			return nil, err
+		}
+		spec.Umask = &mask
+	}
+	if id != nil {
+		spec.Credential = &shimIdentity{Uid: id.uid, Gid: id.gid, Groups: id.groups}
+	}
+	if exe.Isolation != nil && exe.Isolation.needMounts() {
+		mounts, err := exe.Isolation.shimMounts(exe.WorkDir)
+		if err != nil {
+			return nil, err
+		}
+		spec.Mounts = mounts
+	}
+	return spec, nil
+}
+
+// parseRlimit parses `soft:hard` or single value for both limits. `unlimited` (or `infinity`) means no limit
+func parseRlimit(value string) (cur, max uint64, err error) {
+	parts := strings.SplitN(value, ":", 2)
+	cur, err = parseRlimitValue(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	max = cur
+	if len(parts) == 2 {
+		max, err = parseRlimitValue(parts[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if cur > max {
+		return 0, 0, errors.New("soft limit is greater than hard limit")
+	}
+	return cur, max, nil
+}
+
+func parseRlimitValue(value string) (uint64, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "unlimited", "infinity":
+		return rlimInfinity, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid limit %v", value)
+	}
+	return v, nil
+}
+
+func parseUmask(value string) (int, error) {
+	v, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || v > 0777 {
+		return 0, fmt.Errorf("invalid umask %v", value)
+	}
+	return int(v), nil
+}
@@ -0,0 +1,22 @@
+// +build !linux
+
+package pool
+
+import (
+	"errors"
+	"os/exec"
+)
+
+type cgroup struct{}
+
+func newCgroup(res *Resources, name string) (*cgroup, error) {
+	return nil, errors.New("resources are supported only on Linux")
+}
+
+func (cg *cgroup) apply(cmd *exec.Cmd) {}
+
+func (cg *cgroup) startError(err error) error { return err }
+
+func (cg *cgroup) oomKilled() bool { return false }
+
+func (cg *cgroup) close() {}
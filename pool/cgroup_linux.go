@@ -0,0 +1,114 @@
+package pool
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// cgroup of one instance
+type cgroup struct {
+	path string
+	dir  *os.File
+	ooms int // oom_kill counter before start
+}
+
+// newCgroup creates cgroup <root>/<name> and applies limits
+func newCgroup(res *Resources, name string) (*cgroup, error) {
+	settings, err := res.settings()
+	if err != nil {
+		return nil, err
+	}
+	root := res.root()
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	var controllers = map[string]bool{"memory": false} // memory is optional: used for OOM events
+	for _, s := range settings {
+		controllers[s.controller] = true
+	}
+	for controller, required := range controllers {
+		for _, dir := range []string{filepath.Dir(root), root} {
+			err := ioutil.WriteFile(filepath.Join(dir, "cgroup.subtree_control"), []byte("+"+controller), 0644)
+			if err != nil && required {
+				return nil, fmt.Errorf("enable %v controller in %v: %v", controller, dir, err)
+			}
+		}
+	}
+
+	path := filepath.Join(root, strings.Replace(name, "/", "_", -1))
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	for _, s := range settings {
+		if err := ioutil.WriteFile(filepath.Join(path, s.file), []byte(s.value), 0644); err != nil {
+			os.Remove(path)
+			return nil, fmt.Errorf("set %v: %v", s.file, err)
+		}
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	cg := &cgroup{path: path, dir: dir}
+	cg.ooms = cg.oomKills()
+	return cg, nil
+}
+
+// apply cgroup to command: process is placed to cgroup before exec. setAttrs should be called before
+func (cg *cgroup) apply(cmd *exec.Cmd) {
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(cg.dir.Fd())
+}
+
+// startError explains failed start of process placed into cgroup: clone3 with CLONE_INTO_CGROUP requires Linux 5.7+
+func (cg *cgroup) startError(err error) error {
+	if errors.Is(err, syscall.ENOSYS) || errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.E2BIG) {
+		return fmt.Errorf("%v (resources require Linux 5.7+ to start process in cgroup)", err)
+	}
+	return err
+}
+
+// oomKilled checks that OOM killer was used in cgroup since start
+func (cg *cgroup) oomKilled() bool {
+	return cg.oomKills() > cg.ooms
+}
+
+// oomKills reads oom_kill counter from memory.events
+func (cg *cgroup) oomKills() int {
+	f, err := os.Open(filepath.Join(cg.path, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			v, _ := strconv.Atoi(fields[1])
+			return v
+		}
+	}
+	return 0
+}
+
+// close kills remaining processes and removes cgroup
+func (cg *cgroup) close() {
+	cg.dir.Close()
+	ioutil.WriteFile(filepath.Join(cg.path, "cgroup.kill"), []byte("1"), 0644)
+	for i := 0; i < 10; i++ {
+		if err := os.Remove(cg.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,112 @@
+package pool
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// identity of process owner resolved from user, group and supplementary_groups
+type identity struct {
+	uid    uint32
+	gid    uint32
+	groups []uint32
+	user   *user.User // nil if only group is set
+}
+
+// identity resolves user and groups of service. Returns nil if process runs under current user
+func (exe *Executable) identity() (*identity, error) {
+	if exe.User == "" && exe.Group == "" && len(exe.SupplementaryGroups) == 0 {
+		return nil, nil
+	}
+	id := &identity{uid: uint32(os.Getuid()), gid: uint32(os.Getgid())}
+	if exe.User != "" {
+		u, err := lookupUser(exe.User)
+		if err != nil {
+			return nil, err
+		}
+		id.user = u
+		id.uid, err = parseID(u.Uid)
+		if err != nil {
+			return nil, err
+		}
+		id.gid, err = parseID(u.Gid)
+		if err != nil {
+			return nil, err
+		}
+		if len(exe.SupplementaryGroups) == 0 {
+			// like initgroups: all groups of user
+			gids, err := u.GroupIds()
+			if err != nil {
+				return nil, fmt.Errorf("groups of user %v: %v", exe.User, err)
+			}
+			for _, gid := range gids {
+				v, err := parseID(gid)
+				if err != nil {
+					return nil, err
+				}
+				id.groups = append(id.groups, v)
+			}
+		}
+	}
+	if exe.Group != "" {
+		gid, err := lookupGroup(exe.Group)
+		if err != nil {
+			return nil, err
+		}
+		id.gid = gid
+	}
+	for _, name := range exe.SupplementaryGroups {
+		gid, err := lookupGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		id.groups = append(id.groups, gid)
+	}
+	return id, nil
+}
+
+// environment with HOME, USER and LOGNAME of process owner
+func (id *identity) environment(env []string) []string {
+	if id == nil || id.user == nil {
+		return env
+	}
+	var ans = make([]string, 0, len(env)+3)
+	for _, kv := range env {
+		if strings.HasPrefix(kv, "HOME=") || strings.HasPrefix(kv, "USER=") || strings.HasPrefix(kv, "LOGNAME=") {
+			continue
+		}
+		ans = append(ans, kv)
+	}
+	return append(ans, "HOME="+id.user.HomeDir, "USER="+id.user.Username, "LOGNAME="+id.user.Username)
+}
+
+// lookupUser by name or numeric ID
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+// lookupGroup by name or numeric ID
+func lookupGroup(name string) (uint32, error) {
+	if gid, err := parseID(name); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	return parseID(g.Gid)
+}
+
+func parseID(value string) (uint32, error) {
+	v, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ID %v: %v", value, err)
+	}
+	return uint32(v), nil
+}
@@ -0,0 +1,89 @@
+package pool
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DependencyLayers groups supervisors by dependency depth: supervisors in layer N depend only
+// on supervisors from layers before N. References to unknown labels and cycles are reported as errors.
+// Order of supervisors inside one layer is kept as in source slice.
+func DependencyLayers(svs []Supervisor) ([][]Supervisor, error) {
+	deps := make(map[string][]string)
+	for _, sv := range svs {
+		cfg := sv.Config()
+		deps[cfg.Name] = append(deps[cfg.Name], cfg.DependsOn...)
+	}
+	for _, sv := range svs {
+		cfg := sv.Config()
+		for _, dep := range cfg.DependsOn {
+			if _, ok := deps[dep]; !ok {
+				return nil, fmt.Errorf("service %v depends on unknown service %v", cfg.Name, dep)
+			}
+		}
+	}
+
+	depth := make(map[string]int)
+	visiting := make(map[string]bool)
+	var path []string
+	var visit func(label string) (int, error)
+	visit = func(label string) (int, error) {
+		if d, ok := depth[label]; ok {
+			return d, nil
+		}
+		path = append(path, label)
+		defer func() { path = path[:len(path)-1] }()
+		if visiting[label] {
+			start := 0
+			for i, l := range path {
+				if l == label {
+					start = i
+					break
+				}
+			}
+			return 0, fmt.Errorf("dependency cycle: %v", strings.Join(path[start:], " -> "))
+		}
+		visiting[label] = true
+		d := 0
+		for _, dep := range deps[label] {
+			dd, err := visit(dep)
+			if err != nil {
+				return 0, err
+			}
+			if dd+1 > d {
+				d = dd + 1
+			}
+		}
+		visiting[label] = false
+		depth[label] = d
+		return d, nil
+	}
+
+	var layers [][]Supervisor
+	for _, sv := range svs {
+		d, err := visit(sv.Config().Name)
+		if err != nil {
+			return nil, err
+		}
+		for len(layers) <= d {
+			layers = append(layers, nil)
+		}
+		layers[d] = append(layers[d], sv)
+	}
+	return layers, nil
+}
+
+// dependencyDepth returns depth of every known label. On broken graph all labels has same depth
+func (p *Pool) dependencyDepth() map[string]int {
+	ans := make(map[string]int)
+	layers, err := DependencyLayers(p.Supervisors())
+	if err != nil {
+		return ans
+	}
+	for d, layer := range layers {
+		for _, sv := range layer {
+			ans[sv.Config().Name] = d
+		}
+	}
+	return ans
+}
@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDependencyLayers(t *testing.T) {
+	type service struct {
+		name      string
+		dependsOn []string
+	}
+	cases := []struct {
+		name     string
+		services []service
+		layers   [][]string
+		err      string
+	}{
+		{
+			name:     "no services",
+			services: nil,
+			layers:   nil,
+		},
+		{
+			name:     "independent keep source order",
+			services: []service{{"b", nil}, {"a", nil}, {"c", nil}},
+			layers:   [][]string{{"b", "a", "c"}},
+		},
+		{
+			name:     "chain",
+			services: []service{{"app", []string{"cache"}}, {"cache", []string{"db"}}, {"db", nil}},
+			layers:   [][]string{{"db"}, {"cache"}, {"app"}},
+		},
+		{
+			name: "depth is longest path",
+			services: []service{
+				{"web", []string{"db", "api"}},
+				{"api", []string{"db"}},
+				{"db", nil},
+				{"worker", []string{"db"}},
+			},
+			layers: [][]string{{"db"}, {"api", "worker"}, {"web"}},
+		},
+		{
+			name:     "unknown dependency",
+			services: []service{{"app", []string{"db"}}},
+			err:      "service app depends on unknown service db",
+		},
+		{
+			name:     "self dependency",
+			services: []service{{"app", []string{"app"}}},
+			err:      "dependency cycle: app -> app",
+		},
+		{
+			name:     "cycle",
+			services: []service{{"x", nil}, {"a", []string{"b"}}, {"b", []string{"c"}}, {"c", []string{"a"}}},
+			err:      "dependency cycle: a -> b -> c -> a",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var svs []Supervisor
+			for _, s := range tc.services {
+				svs = append(svs, &Executable{Name: s.name, DependsOn: s.dependsOn})
+			}
+			layers, err := DependencyLayers(svs)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names [][]string
+			for _, layer := range layers {
+				var labels []string
+				for _, sv := range layer {
+					labels = append(labels, sv.Config().Name)
+				}
+				names = append(names, labels)
+			}
+			if !reflect.DeepEqual(names, tc.layers) {
+				t.Fatalf("expected layers %v, got %v", tc.layers, names)
+			}
+		})
+	}
+}
@@ -2,12 +2,15 @@ package pool
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,25 +19,116 @@ import (
 //  Executable - basic information about process.
 //  实现了Supervisor接口
 type Executable struct {
-	Name           string            `yaml:"label,omitempty"`         // Human-readable label for process. If not set - command used
-	Command        string            `yaml:"command"`                 // Executable
-	Args           []string          `yaml:"args,omitempty"`          // Arguments to command
-	Environment    map[string]string `yaml:"environment,omitempty"`   // Additional environment variables
-	EnvFiles       []string          `yaml:"envFiles"`                // Additional environment variables from files (not found files ignored). Format key=value
-	WorkDir        string            `yaml:"workdir,omitempty"`       // Working directory. If not set - current dir used
-	StopTimeout    time.Duration     `yaml:"stop_timeout,omitempty"`  // Timeout before terminate process
-	RestartTimeout time.Duration     `yaml:"restart_delay,omitempty"` // Restart delay
-	Restart        int               `yaml:"restart,omitempty"`       // How much restart allowed. -1 infinite
-	LogFile        string            `yaml:"logFile,omitempty"`       // if empty - only to log. If not absolute - relative to workdir
-	RawOutput      bool              `yaml:"raw,omitempty"`           // print stdout as-is without prefixes
-
-	log        *log.Logger
-	loggerInit sync.Once
+	Name                string            `yaml:"label,omitempty"`                // Human-readable label for process. If not set - command used
+	Command             string            `yaml:"command"`                        // Executable
+	Args                []string          `yaml:"args,omitempty"`                 // Arguments to command
+	Environment         map[string]string `yaml:"environment,omitempty"`          // Additional environment variables
+	EnvFiles            []string          `yaml:"envFiles"`                       // Additional environment variables from files (not found files ignored). Format key=value
+	WorkDir             string            `yaml:"workdir,omitempty"`              // Working directory. If not set - current dir used
+	StopTimeout         time.Duration     `yaml:"stop_timeout,omitempty"`         // Timeout before terminate process
+	RestartTimeout      time.Duration     `yaml:"restart_delay,omitempty"`        // Restart delay
+	Restart             int               `yaml:"restart,omitempty"`              // How much restart allowed. -1 infinite
+	LogFile             string            `yaml:"logFile,omitempty"`              // if empty - only to log. If not absolute - relative to workdir
+	RawOutput           bool              `yaml:"raw,omitempty"`                  // print stdout as-is without prefixes
+	DependsOn           []string          `yaml:"depends_on,omitempty"`           // Labels of services that have to be started before this one
+	Readiness           *Probe            `yaml:"readiness,omitempty"`            // Check that service is ready. If not set - ready right after start
+	Liveness            *Probe            `yaml:"liveness,omitempty"`             // Periodic check of ready service. Service restarted after too many failures
+	Backoff             *Backoff          `yaml:"backoff,omitempty"`              // Exponential restart delay and crash loop detection. If not set - fixed restart_delay
+	RestartPolicy       string            `yaml:"restart_policy,omitempty"`       // When to restart: always (default), on-failure, never, unless-stopped
+	SuccessExitCodes    []int             `yaml:"success_exit_codes,omitempty"`   // Exit codes considered as success. Default 0
+	Replicas            int               `yaml:"replicas,omitempty"`             // How much instances started by StartAll. Default 1
+	StopSignal          string            `yaml:"stop_signal,omitempty"`          // Signal for graceful stop. Default SIGINT
+	StopCommand         *Hook             `yaml:"stop_command,omitempty"`         // Command to stop service instead of signal. PID in MONEXEC_PID
+	StopSequence        []StopStage       `yaml:"stop_sequence,omitempty"`        // Escalation of signals on stop. Overrides stop_signal and stop_timeout
+	User                string            `yaml:"user,omitempty"`                 // Run process as user (name or UID). Requires root
+	Group               string            `yaml:"group,omitempty"`                // Primary group (name or GID). Default - group of user
+	SupplementaryGroups []string          `yaml:"supplementary_groups,omitempty"` // Additional groups. Default - all groups of user
+	Resources           *Resources        `yaml:"resources,omitempty"`            // cgroup v2 limits (memory, CPU, pids, IO). Linux only
+	Rlimits             map[string]string `yaml:"rlimits,omitempty"`              // Resource limits like nofile: 65536 or core: unlimited. Linux only
+	Nice                *int              `yaml:"nice,omitempty"`                 // Scheduling priority (-20..19). Linux only
+	OOMScoreAdj         *int              `yaml:"oom_score_adj,omitempty"`        // OOM killer score adjustment (-1000..1000). Linux only
+	Umask               string            `yaml:"umask,omitempty"`                // File mode creation mask in octal (0027). Linux only
+	NoNewPrivs          bool              `yaml:"no_new_privs,omitempty"`         // Forbid gaining privileges by setuid binaries. Linux only
+	Isolation           *Isolation        `yaml:"isolation,omitempty"`            // Private namespaces, chroot, read-only binds. Linux only
+	StatsInterval       time.Duration     `yaml:"stats_interval,omitempty"`       // Interval of resources usage sampling. Default 5s, negative disables
+	MaxRSS              string            `yaml:"max_rss,omitempty"`              // Restart if resident memory of process group is above (suffixes K, M, G, T)
+	MaxCPUPercent       float64           `yaml:"max_cpu_percent,omitempty"`      // Restart if CPU usage is above for max_cpu_window. 100 is one CPU
+	MaxCPUWindow        time.Duration     `yaml:"max_cpu_window,omitempty"`       // How long CPU usage should be above max_cpu_percent. Default 1m
+	MaxOpenFiles        int               `yaml:"max_open_files,omitempty"`       // Restart if process group opened more files
+	PreStart            []Hook            `yaml:"pre_start,omitempty"`            // Commands before start. Failed command aborts start attempt
+	PostStart           []Hook            `yaml:"post_start,omitempty"`           // Commands after start (and readiness). Failed command restarts process
+	PreStop             []Hook            `yaml:"pre_stop,omitempty"`             // Commands before stop of process by supervisor
+	PostStop            []Hook            `yaml:"post_stop,omitempty"`            // Commands after process exit
+	Schedule            string            `yaml:"schedule,omitempty"`             // Run by cron expression or @every 5m instead of restart loop
+	Overlap             string            `yaml:"overlap,omitempty"`              // If previous run is still active: skip (default), queue, replace
+	MaxRuntime          time.Duration     `yaml:"max_runtime,omitempty"`          // Stop scheduled run after timeout
 }
 
+// Validate service definition
+func (exe *Executable) Validate() error {
+	if exe.Readiness != nil {
+		if err := exe.Readiness.validate(false); err != nil {
+			return fmt.Errorf("%v: readiness: %v", exe.Name, err)
+		}
+	}
+	if exe.Liveness != nil {
+		if err := exe.Liveness.validate(true); err != nil {
+			return fmt.Errorf("%v: liveness: %v", exe.Name, err)
+		}
+	}
+	if exe.Backoff != nil {
+		if err := exe.Backoff.validate(); err != nil {
+			return fmt.Errorf("%v: backoff: %v", exe.Name, err)
+		}
+	}
+	switch exe.RestartPolicy {
+	case "", RestartAlways, RestartOnFailure, RestartNever, RestartUnlessStopped:
+	default:
+		return fmt.Errorf("%v: unknown restart policy %v", exe.Name, exe.RestartPolicy)
+	}
+	if err := exe.validateStop(); err != nil {
+		return fmt.Errorf("%v: %v", exe.Name, err)
+	}
+	for _, err := range []error{
+		validateHooks("pre_start", exe.PreStart),
+		validateHooks("post_start", exe.PostStart),
+		validateHooks("pre_stop", exe.PreStop),
+		validateHooks("post_stop", exe.PostStop),
+	} {
+		if err != nil {
+			return fmt.Errorf("%v: %v", exe.Name, err)
+		}
+	}
+	if err := exe.validateSchedule(); err != nil {
+		return fmt.Errorf("%v: %v", exe.Name, err)
+	}
+	if _, err := exe.identity(); err != nil {
+		return fmt.Errorf("%v: %v", exe.Name, err)
+	}
+	if err := exe.validateAttrs(); err != nil {
+		return fmt.Errorf("%v: %v", exe.Name, err)
+	}
+	if err := exe.validateWatchdog(); err != nil {
+		return fmt.Errorf("%v: %v", exe.Name, err)
+	}
+	if exe.Isolation != nil {
+		if err := exe.Isolation.validate(); err != nil {
+			return fmt.Errorf("%v: isolation: %v", exe.Name, err)
+		}
+	}
+	if exe.Resources != nil {
+		if err := exe.Resources.validate(); err != nil {
+			return fmt.Errorf("%v: resources: %v", exe.Name, err)
+		}
+	}
+	return nil
+}
+
+// loggers per label or instance ID
+var loggers sync.Map
+
 func (exe *Executable) WithName(name string) *Executable {
 	cp := *exe
-	cp.loggerInit = sync.Once{}
 	cp.Name = name
 	return &cp
 }
@@ -56,41 +150,27 @@ func (exe *Executable) Env(arg, value string) *Executable {
 
 //获取Executable中绑定的logger 即$exe.log
 func (exe *Executable) logger() *log.Logger {
-	exe.loggerInit.Do(func() {
-		exe.log = log.New(os.Stderr, "["+exe.Name+"] ", log.LstdFlags)
-	})
-	return exe.log
+	return loggerFor(exe.Name)
 }
 
-// try to do graceful process termination by sending SIGKILL. If no response after StopTimeout
-// SIGTERM is used
-func (exe *Executable) stopOrKill(cmd *exec.Cmd, res <-chan error) error {
-	exe.logger().Println("Sending SIGINT")
-	err := cmd.Process.Signal(os.Interrupt)
-	if err != nil {
-		exe.logger().Println("Failed send SIGINT:", err)
+// shared logger for label or instance ID
+func loggerFor(name string) *log.Logger {
+	if logger, ok := loggers.Load(name); ok {
+		return logger.(*log.Logger)
 	}
-
-	select {
-	case err = <-res:
-		exe.logger().Println("Process graceful stopped")
-	case <-time.After(exe.StopTimeout):
-		exe.logger().Println("Process graceful shutdown waiting timeout")
-		err = kill(cmd, exe.logger())
-	}
-	return err
+	logger, _ := loggers.LoadOrStore(name, log.New(os.Stderr, "["+name+"] ", log.LstdFlags))
+	return logger.(*log.Logger)
 }
 
-//  run once executable, wrap output and wait for finish
-//  运行一次executable即Supervisor 包装输出并等待执行完成
-func (exe *Executable) run(ctx context.Context) error {
-	cmd := exec.Command(exe.Command, exe.Args...)
+// environment for process: system, configured and from files
+func (exe *Executable) environment() []string {
+	var env []string
 	for _, param := range os.Environ() {
-		cmd.Env = append(cmd.Env, param)
+		env = append(env, param)
 	}
 	if exe.Environment != nil {
 		for k, v := range exe.Environment {
-			cmd.Env = append(cmd.Env, k+"="+v)
+			env = append(env, k+"="+v)
 		}
 	}
 	for _, fileName := range exe.EnvFiles {
@@ -100,20 +180,51 @@ func (exe *Executable) run(ctx context.Context) error {
 			continue
 		}
 		for k, v := range params {
-			cmd.Env = append(cmd.Env, k+"="+v)
+			env = append(env, k+"="+v)
 		}
 	}
+	return env
+}
+
+//  run once executable, wrap output and wait for finish
+//  运行一次executable即Supervisor 包装输出并等待执行完成
+func (exe *Executable) run(ctx context.Context, rn *runnable) error {
+	logger := rn.log
+	cmd := exec.Command(exe.Command, exe.Args...)
+	id, err := exe.identity()
+	if err != nil {
+		return err
+	}
+	cmd.Env = append(id.environment(exe.environment()), "MONEXEC_INSTANCE_ID="+rn.ID(), "MONEXEC_INSTANCE_INDEX="+strconv.Itoa(rn.index))
 	if exe.WorkDir != "" {
 		cmd.Dir = exe.WorkDir
 	}
 
-	setAttrs(cmd)
+	if err := setAttrs(cmd, id); err != nil {
+		return err
+	}
+	if err := exe.isolate(cmd); err != nil {
+		return err
+	}
+	if err := exe.wrapShim(cmd, id); err != nil {
+		return err
+	}
+	var cg *cgroup
+	if exe.Resources != nil {
+		cg, err = newCgroup(exe.Resources, rn.ID())
+		if err != nil {
+			logger.Println("Failed create cgroup:", err)
+			return err
+		}
+		defer cg.close()
+		cg.apply(cmd)
+	}
 
 	var outputs []io.Writer
 	var stderr []io.Writer
 	var stdout []io.Writer
 
-	output := NewLoggerStream(exe.logger(), "|ServiceOut  ▶▶▶| ")
+	output := NewLoggerStream(logger, "|ServiceOut  ▶▶▶| ")
 	outputs = append(outputs, output)
 	defer output.Close()
 	stderr = outputs
@@ -122,6 +233,27 @@ func (exe *Executable) run(ctx context.Context) error {
 	if exe.RawOutput {
 		stdout = append(stdout, os.Stdout)
 	}
+	stdout = append(stdout, rn.output.writer())
+	stderr = append(stderr, rn.output.writer())
+
+	var readiness *prober
+	if exe.Readiness != nil {
+		pr, err := newProber(exe.Readiness, exe, cmd.Env, logger)
+		if err != nil {
+			return err
+		}
+		readiness = pr
+		stdout = append(stdout, readiness)
+		stderr = append(stderr, readiness)
+	}
+	var liveness *prober
+	if exe.Liveness != nil {
+		pr, err := newProber(exe.Liveness, exe, cmd.Env, logger)
+		if err != nil {
+			return err
+		}
+		liveness = pr
+	}
 
 	res := make(chan error, 1)
 
@@ -160,7 +292,7 @@ func (exe *Executable) run(ctx context.Context) error {
 			rotatelogs.WithRotationCount(20),          // 最多20个文件
 		)
 		if errOut != nil {
-			exe.logger().Println("Failed open stdout log file: ", errOut)
+			logger.Println("Failed open stdout log file: ", errOut)
 		}
 		logFileErr, errErr := rotatelogs.New(
 			logNameSlice[0]+"_err.%F."+logNameSlice[1],
@@ -171,7 +303,7 @@ func (exe *Executable) run(ctx context.Context) error {
 			rotatelogs.WithRotationCount(20),          // 最多20个文件
 		)
 		if errErr != nil {
-			exe.logger().Println("Failed open stderr log file: ", errErr)
+			logger.Println("Failed open stderr log file: ", errErr)
 		}
 		defer logFileOut.Close()
 		defer logFileErr.Close()
@@ -187,40 +319,158 @@ func (exe *Executable) run(ctx context.Context) error {
 	cmd.Stderr = logStderrStream
 	cmd.Stdout = logStdoutStream
 
-	err := cmd.Start()
-	if err == nil {
-		exe.logger().Println("Started with PID", cmd.Process.Pid)
-	} else {
-		exe.logger().Println("Failed start `", exe.Command, strings.Join(exe.Args, " "), "` :", err)
+	if err := exe.runHooks(ctx, "pre_start", exe.PreStart, cmd.Env, logger); err != nil {
+		logger.Println("Start aborted:", err)
+		return err
 	}
 
-	go func() { res <- cmd.Wait() }()
+	err = cmd.Start()
+	if err != nil && cg != nil {
+		err = cg.startError(err)
+	}
+	if err != nil {
+		logger.Println("Failed start `", exe.Command, strings.Join(exe.Args, " "), "` :", err)
+		return err
+	}
+	logger.Println("Started with PID", cmd.Process.Pid)
+	rn.processStarted(cmd.Process)
+	hookEnv := append(cmd.Env[:len(cmd.Env):len(cmd.Env)], "MONEXEC_PID="+strconv.Itoa(cmd.Process.Pid))
+
+	// reasons to stop process from monitors
+	kill := make(chan error, 1)
+
+	sampleCtx, stopSampling := context.WithCancel(ctx)
+	defer stopSampling()
+	go rn.sample(sampleCtx, cmd.Process.Pid, kill)
+
+	probeCtx, cancelProbes := context.WithCancel(ctx)
+	probesDone := make(chan struct{})
+	go func() {
+		defer close(probesDone)
+		if readiness != nil {
+			if readiness.wait(probeCtx) != nil {
+				return
+			}
+			logger.Println("Ready")
+		}
+		// kill is shared with resources watchdog: don't block if process is already stopping
+		if err := exe.runHooks(probeCtx, "post_start", exe.PostStart, hookEnv, logger); err != nil {
+			select {
+			case kill <- err:
+			case <-probeCtx.Done():
+			}
+			return
+		}
+		rn.markReady(ctx)
+		if liveness != nil {
+			if reason := liveness.watch(probeCtx); reason != nil {
+				select {
+				case kill <- reason:
+				case <-probeCtx.Done():
+				}
+			}
+		}
+	}()
+	defer func() {
+		cancelProbes()
+		<-probesDone
+	}()
+
+	go func() {
+		err := cmd.Wait()
+		rn.processExited(err)
+		res <- err
+	}()
+	stop := func() error {
+		if err := exe.runHooks(context.Background(), "pre_stop", exe.PreStop, hookEnv, logger); err != nil {
+			logger.Println(err)
+		}
+		return exe.stopOrKill(cmd, cmd.Env, res, logger)
+	}
 	select {
 	case <-ctx.Done():
-		err = exe.stopOrKill(cmd, res)
+		err = stop()
+	case reason := <-kill:
+		logger.Println("Stopping:", reason)
+		stop()
+		err = reason
+	case <-rn.restartRequest:
+		logger.Println("Restart requested")
+		stop()
+		err = ErrRestartRequested
 	case err = <-res:
+		if cg != nil && cg.oomKilled() {
+			logger.Println("Killed by OOM killer")
+			err = &oomKilled{err: err}
+		}
+	}
+	if err := exe.runHooks(context.Background(), "post_stop", exe.PostStop, hookEnv, logger); err != nil {
+		logger.Println(err)
 	}
 	return err
 }
 
+// State of instance
+type State string
+
+const (
+	StateStarting  State = "starting"   // process is starting or waiting for readiness
+	StateReady     State = "ready"      // process is started and passed readiness check
+	StateStopped   State = "stopped"    // process is not running
+	StateCrashLoop State = "crash-loop" // process is not running and restarts too often (flapping)
+	StateScheduled State = "scheduled"  // scheduled service is waiting for next run
+)
+
 //实现了Instance接口
 type runnable struct {
-	Executable *Executable `json:"config"`
-	Running    bool        `json:"running"`
-	pool       *Pool
-	closer     func()
-	done       chan struct{}
+	Executable     *Executable `json:"config"`
+	Running        bool        `json:"running"`
+	index          int
+	log            *log.Logger
+	output         *logTail
+	usage          *usageHistory
+	process        *os.Process
+	restartRequest chan struct{}
+	state          State
+	pid            int
+	startedAt      time.Time
+	restarts       int
+	lastExitCode   *int
+	lastSignal     string
+	lastError      string
+	lastRun        time.Time
+	nextRun        time.Time
+	stopRequested  bool      // stop was requested by Stop
+	stopSignal     os.Signal // stop signal sent to current process by Signal
+	stopSignalAt   time.Time
+	pool           *Pool
+	closer         func()
+	done           chan struct{}
+	ready          chan struct{} // closed after first readiness
+	readyOnce      sync.Once
+	lock           sync.RWMutex
 }
 
 //  启动Executable 即Supervisor
-//  返回Instance 即runnable
-func (exe *Executable) Start(ctx context.Context, pool *Pool) Instance {
+//  返回Instance 即runnable. index - 副本序号
+func (exe *Executable) Start(ctx context.Context, pool *Pool, index int) Instance {
 	chCtx, closer := context.WithCancel(ctx)
 	run := &runnable{
-		Executable: exe,
-		closer:     closer,
-		done:       make(chan struct{}),
-		pool:       pool,
+		Executable:     exe,
+		index:          index,
+		output:         newLogTail(defaultLogTailSize),
+		usage:          newUsageHistory(defaultStatsHistory),
+		restartRequest: make(chan struct{}, 1),
+		closer:         closer,
+		done:           make(chan struct{}),
+		ready:          make(chan struct{}),
+		state:          StateStopped,
+		pool:           pool,
+	}
+	if index > 0 || exe.Replicas > 1 {
+		run.log = loggerFor(run.ID())
+	} else {
+		run.log = exe.logger()
 	}
 	go run.run(chCtx)
 	return run
@@ -232,47 +482,191 @@ func (exe *Executable) Config() *Executable { return exe }
 func (rn *runnable) run(ctx context.Context) {
 	defer rn.closer()
 	defer close(rn.done)
-	restarts := rn.Executable.Restart
+	restarts := newRestartTracker(rn.Executable, rn.log)
 	rn.pool.OnSpawned(ctx, rn)
+	if rn.Executable.Schedule != "" {
+		rn.runScheduled(ctx)
+		rn.pool.OnFinished(ctx, rn)
+		return
+	}
 LOOP:
 	for {
-		rn.Running = true
-		rn.pool.OnStarted(ctx, rn)
-		err := rn.Executable.run(ctx) //执行Executable
-		if err != nil {
-			rn.Executable.logger().Println("stopped with error:", err)
+		rn.setState(true, StateStarting)
+		started := time.Now()
+		err := rn.Executable.run(ctx, rn) //执行Executable, OnStarted在进程就绪后触发
+		if err == ErrRestartRequested {
+			// 手动重启: 不消耗重启次数，也不等待
+			rn.runFinished(err)
+			rn.setState(false, StateStopped)
+			rn.pool.OnStopped(ctx, rn, err)
+			rn.restarted()
+			continue
+		}
+		err = rn.Executable.exitReason(err)
+		rn.runFinished(err)
+		if state, ok := err.(*ExitError); ok && state.Success {
+			rn.log.Println("stopped:", err)
+		} else if err != nil {
+			rn.log.Println("stopped with error:", err)
 		} else {
-			rn.Executable.logger().Println("stopped")
+			rn.log.Println("stopped")
+		}
+		restarts.stopped(time.Since(started))
+		restart := rn.Executable.shouldRestart(err, rn.isStopRequested(err) || ctx.Err() != nil)
+		if restart && restarts.crashLoop() {
+			rn.log.Println("crash loop detected")
+			rn.setState(false, StateCrashLoop)
+		} else {
+			rn.setState(false, StateStopped)
 		}
-		rn.Running = false
 		rn.pool.OnStopped(ctx, rn, err)
-		if restarts != -1 {
-			if restarts <= 0 {
-				rn.Executable.logger().Println("max restarts attempts reached")
-				break
-			} else {
-				restarts--
-			}
+		if !restart {
+			rn.log.Println("no restart due to restart policy", rn.Executable.RestartPolicy)
+			break
+		}
+		delay, ok := restarts.next()
+		if !ok {
+			rn.log.Println("max restarts attempts reached")
+			break
 		}
-		rn.Executable.logger().Println("waiting", rn.Executable.RestartTimeout)
+		rn.log.Println("waiting", delay)
 		select {
-		case <-time.After(rn.Executable.RestartTimeout):
+		case <-time.After(delay):
+			rn.restarted()
+		case <-rn.restartRequest:
+			rn.restarted()
 		case <-ctx.Done():
-			rn.Executable.logger().Println("instance done:", ctx.Err())
+			rn.log.Println("instance done:", ctx.Err())
 			break LOOP
 		}
 	}
-	rn.Executable.logger().Println("instance restart loop done")
+	rn.log.Println("instance restart loop done")
 	rn.pool.OnFinished(ctx, rn)
 }
 
+func (rn *runnable) setState(running bool, state State) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.Running = running
+	rn.state = state
+}
+
+// 进程就绪: 更新状态并触发OnStarted
+func (rn *runnable) markReady(ctx context.Context) {
+	rn.setState(true, StateReady)
+	rn.readyOnce.Do(func() { close(rn.ready) })
+	rn.pool.OnStarted(ctx, rn)
+}
+
+// wait for first readiness of instance or for finish
+func (rn *runnable) waitReady(done <-chan struct{}) {
+	select {
+	case <-rn.ready:
+	case <-rn.done:
+	case <-done:
+	}
+}
+
+func (rn *runnable) MarshalJSON() ([]byte, error) {
+	rn.lock.RLock()
+	defer rn.lock.RUnlock()
+	type plain runnable
+	return json.Marshal(struct {
+		*plain
+		ID    string `json:"id"`
+		Index int    `json:"index"`
+		Status
+	}{(*plain)(rn), rn.ID(), rn.index, rn.status()})
+}
+
+// ID of instance: label and index of replica
+func (rn *runnable) ID() string { return InstanceID(rn.Executable.Name, rn.index) }
+
+func (rn *runnable) Index() int { return rn.index }
+
+// Logs returns last n lines of process output
+func (rn *runnable) Logs(n int) []string { return rn.output.Last(n) }
+
+func (rn *runnable) State() State {
+	rn.lock.RLock()
+	defer rn.lock.RUnlock()
+	return rn.state
+}
+
 func (rn *runnable) Supervisor() Supervisor { return rn.Executable }
 
 func (rn *runnable) Config() *Executable { return rn.Executable }
 
 func (rn *runnable) Pool() *Pool { return rn.pool }
 
+// Finished checks that restart loop of instance is done. Finished instance is kept in pool until it is stopped
+func (rn *runnable) Finished() bool {
+	select {
+	case <-rn.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stopped checks that instance is stopped by request (pool stop, service removal on reload) instead of finishing by itself
+func (rn *runnable) Stopped() bool {
+	rn.lock.RLock()
+	defer rn.lock.RUnlock()
+	return rn.stopRequested
+}
+
+// Restart process of instance without waiting restart delay. Restart budget is not affected
+func (rn *runnable) Restart() error {
+	if rn.Finished() {
+		return ErrFinished
+	}
+	select {
+	case rn.restartRequest <- struct{}{}:
+	default: // already requested
+	}
+	return nil
+}
+
+// Signal sends signal to current process of instance. Stop signal is considered as stop request
+// for restart policy unless-stopped
+func (rn *runnable) Signal(sig os.Signal) error {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	if rn.process == nil {
+		return ErrNotRunning
+	}
+	if err := rn.process.Signal(sig); err != nil {
+		return err
+	}
+	if rn.Executable.isStopSignal(sig) {
+		rn.stopSignal = sig
+		rn.stopSignalAt = time.Now()
+	}
+	return nil
+}
+
+// isStopRequested checks that process exited by request: instance is stopped or process exited because of stop signal
+// (terminated by it or exited within stop timeout after it). Ignored stop signal doesn't affect later exit
+func (rn *runnable) isStopRequested(exitErr error) bool {
+	rn.lock.RLock()
+	defer rn.lock.RUnlock()
+	if rn.stopRequested {
+		return true
+	}
+	if rn.stopSignal == nil {
+		return false
+	}
+	if state, ok := exitErr.(*ExitError); ok && state.Signal == rn.stopSignal.String() {
+		return true
+	}
+	return time.Since(rn.stopSignalAt) <= rn.Executable.StopTimeout
+}
+
 func (rn *runnable) Stop() {
+	rn.lock.Lock()
+	rn.stopRequested = true
+	rn.lock.Unlock()
 	rn.closer()
 	<-rn.done
 }
@@ -0,0 +1,135 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Restart policies
+const (
+	RestartAlways        = "always"         // restart after any exit (default)
+	RestartOnFailure     = "on-failure"     // restart only if process failed
+	RestartNever         = "never"          // never restart
+	RestartUnlessStopped = "unless-stopped" // restart unless stop was requested through monexec
+)
+
+var (
+	ErrRestartRequested = errors.New("restart requested")      // stop reason of process restarted by request
+	ErrNotRunning       = errors.New("process is not running") // instance has no running process
+	ErrFinished         = errors.New("instance finished")      // instance restart loop is done
+)
+
+// ExitError describes how process terminated. Passed to OnStopped instead of raw error of process
+type ExitError struct {
+	Code      int    `json:"code"`                 // exit code or -1 if process terminated by signal
+	Signal    string `json:"signal,omitempty"`     // name of signal that terminated process
+	Success   bool   `json:"success"`              // exit code is one of success exit codes
+	OOMKilled bool   `json:"oom_killed,omitempty"` // process was killed by OOM killer of cgroup (see resources)
+	Err       error  `json:"-"`                    // original error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return "exit status 0"
+	}
+	return e.Err.Error()
+}
+
+// exitCode extracts exit code and termination signal from error of process. Returns false for errors
+// that are not related to process exit
+func exitCode(err error) (code int, signal string, ok bool) {
+	if err == nil {
+		return 0, "", true
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, "", false
+	}
+	code = exitErr.ExitCode()
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		signal = status.Signal().String()
+	}
+	return code, signal, true
+}
+
+// exitReason converts error of finished process to ExitError. Clean exit (with code from success codes) is nil
+// if it's code is 0. Other errors (failed start, liveness and so on) are returned as-is
+func (exe *Executable) exitReason(err error) error {
+	code, signal, ok := exitCode(err)
+	if !ok {
+		return err
+	}
+	state := &ExitError{Code: code, Signal: signal, Err: err}
+	var oom *oomKilled
+	state.OOMKilled = errors.As(err, &oom)
+	state.Success = state.Signal == "" && !state.OOMKilled && exe.isSuccessCode(state.Code)
+	if state.Success && state.Code == 0 {
+		return nil
+	}
+	return state
+}
+
+func (exe *Executable) isSuccessCode(code int) bool {
+	if len(exe.SuccessExitCodes) == 0 {
+		return code == 0
+	}
+	for _, c := range exe.SuccessExitCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRestart checks restart policy against stop reason. stopRequested - stop was requested through monexec
+// (instance stop, pool termination or stop signal sent by control socket or REST API)
+func (exe *Executable) shouldRestart(err error, stopRequested bool) bool {
+	switch exe.RestartPolicy {
+	case RestartNever:
+		return false
+	case RestartOnFailure:
+		if err == nil {
+			return false
+		}
+		state, ok := err.(*ExitError)
+		return !ok || !state.Success
+	case RestartUnlessStopped:
+		return !stopRequested
+	}
+	return true
+}
+
+// isStopSignal checks that signal terminates service: SIGINT, SIGTERM, SIGKILL or signal from stop configuration
+func (exe *Executable) isStopSignal(sig os.Signal) bool {
+	switch sig {
+	case syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL:
+		return true
+	}
+	names := []string{exe.StopSignal}
+	for _, stage := range exe.StopSequence {
+		names = append(names, stage.Signal)
+	}
+	for _, name := range names {
+		if parsed, err := ParseSignal(name); err == nil && parsed == sig {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSignal parses signal by name (SIGTERM, TERM, term) or by number
+func ParseSignal(name string) (syscall.Signal, error) {
+	if num, err := strconv.Atoi(name); err == nil && num > 0 {
+		return syscall.Signal(num), nil
+	}
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %v", name)
+	}
+	return sig, nil
+}
@@ -0,0 +1,87 @@
+// +build !windows
+
+package pool
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShouldRestart(t *testing.T) {
+	failed := &ExitError{Code: 1}
+	success := &ExitError{Code: 3, Success: true}
+	killed := &ExitError{Code: -1, Signal: syscall.SIGKILL.String()}
+	cases := []struct {
+		policy        string
+		err           error
+		stopRequested bool
+		restart       bool
+	}{
+		{"", nil, false, true},
+		{RestartAlways, failed, false, true},
+		{RestartNever, failed, false, false},
+		{RestartOnFailure, nil, false, false},
+		{RestartOnFailure, success, false, false},
+		{RestartOnFailure, failed, false, true},
+		{RestartOnFailure, killed, false, true},
+		{RestartOnFailure, errors.New("liveness"), false, true},
+		{RestartUnlessStopped, nil, false, true},
+		{RestartUnlessStopped, killed, false, true},
+		{RestartUnlessStopped, killed, true, false},
+		{RestartUnlessStopped, nil, true, false},
+	}
+	for _, tc := range cases {
+		exe := Executable{RestartPolicy: tc.policy}
+		if restart := exe.shouldRestart(tc.err, tc.stopRequested); restart != tc.restart {
+			t.Errorf("%q %v (stop requested %v): expected restart %v", tc.policy, tc.err, tc.stopRequested, tc.restart)
+		}
+	}
+}
+
+func TestIsStopRequested(t *testing.T) {
+	terminated := &ExitError{Code: -1, Signal: syscall.SIGTERM.String()}
+	crashed := &ExitError{Code: -1, Signal: syscall.SIGSEGV.String()}
+	cases := []struct {
+		name      string
+		stopped   bool
+		signal    syscall.Signal
+		signalAgo time.Duration
+		exit      error
+		requested bool
+	}{
+		{name: "no request", exit: crashed},
+		{name: "instance stopped", stopped: true, exit: crashed, requested: true},
+		{name: "terminated by stop signal", signal: syscall.SIGTERM, signalAgo: time.Hour, exit: terminated, requested: true},
+		{name: "exited after stop signal", signal: syscall.SIGTERM, signalAgo: 0, exit: nil, requested: true},
+		{name: "crashed long after ignored stop signal", signal: syscall.SIGTERM, signalAgo: time.Hour, exit: crashed},
+	}
+	for _, tc := range cases {
+		rn := &runnable{Executable: &Executable{StopTimeout: time.Minute}, stopRequested: tc.stopped}
+		if tc.signal != 0 {
+			rn.stopSignal = tc.signal
+			rn.stopSignalAt = time.Now().Add(-tc.signalAgo)
+		}
+		if requested := rn.isStopRequested(tc.exit); requested != tc.requested {
+			t.Errorf("%v: expected stop requested %v", tc.name, tc.requested)
+		}
+	}
+}
+
+func TestIsStopSignal(t *testing.T) {
+	exe := Executable{StopSignal: "QUIT", StopSequence: []StopStage{{Signal: "USR2"}}}
+	for sig, expected := range map[syscall.Signal]bool{
+		syscall.SIGINT:  true,
+		syscall.SIGTERM: true,
+		syscall.SIGKILL: true,
+		syscall.SIGQUIT: true,
+		syscall.SIGUSR2: true,
+		syscall.SIGHUP:  false,
+		syscall.SIGUSR1: false,
+	} {
+		if exe.isStopSignal(sig) != expected {
+			t.Errorf("%v: expected stop signal %v", sig, expected)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package pool
+
+import (
+	"fmt"
+	"sync"
+)
+
+// HandlerInfo - event handler (plugin) registered in pool
+type HandlerInfo struct {
+	Name   string `json:"name"`            // name of plugin. Go type for handlers added by Watch
+	Type   string `json:"type"`            // Go type of handler
+	Active bool   `json:"active"`          // handler receives events
+	Error  string `json:"error,omitempty"` // error of plugin preparation
+}
+
+// watchedHandler delivers events to handler until it is removed
+type watchedHandler struct {
+	handler EventHandler
+	info    HandlerInfo
+	lock    sync.Mutex
+	removed bool
+	calls   sync.WaitGroup // events in progress
+}
+
+func newWatchedHandler(name string, handler EventHandler, prepareErr error) *watchedHandler {
+	kind := fmt.Sprintf("%T", handler)
+	if name == "" {
+		name = kind
+	}
+	wh := &watchedHandler{handler: handler, info: HandlerInfo{Name: name, Type: kind, Active: prepareErr == nil}}
+	if prepareErr != nil {
+		wh.info.Error = prepareErr.Error()
+	}
+	return wh
+}
+
+// deliver event to handler if it is active and not removed
+func (wh *watchedHandler) deliver(event func(handler EventHandler)) {
+	wh.lock.Lock()
+	if wh.removed || !wh.info.Active {
+		wh.lock.Unlock()
+		return
+	}
+	wh.calls.Add(1)
+	wh.lock.Unlock()
+	defer wh.calls.Done()
+	event(wh.handler)
+}
+
+// remove handler and wait for events in progress
+func (wh *watchedHandler) remove() {
+	wh.lock.Lock()
+	wh.removed = true
+	wh.lock.Unlock()
+	wh.calls.Wait()
+}
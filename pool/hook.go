@@ -0,0 +1,78 @@
+package pool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"time"
+)
+
+const defaultHookTimeout = 30 * time.Second
+
+// Hook - additional command executed by supervisor around service process
+type Hook struct {
+	Command string        `yaml:"command"`           // Executable
+	Args    []string      `yaml:"args,omitempty"`    // Arguments to command
+	Timeout time.Duration `yaml:"timeout,omitempty"` // Time limit for command. Default 30s
+}
+
+func (h *Hook) validate() error {
+	if h.Command == "" {
+		return errors.New("command not set")
+	}
+	return nil
+}
+
+// run hook with environment and working directory of service. Output is written to service log
+func (h *Hook) run(ctx context.Context, exe *Executable, env []string, logger *log.Logger) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, h.Command, h.Args...)
+	cmd.Dir = exe.WorkDir
+	cmd.Env = env
+	id, err := exe.identity()
+	if err != nil {
+		return err
+	}
+	if err := setAttrs(cmd, id); err != nil {
+		return err
+	}
+	output, err := cmd.CombinedOutput()
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		logger.Println("|"+h.Command+"|", scanner.Text())
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New(h.Command + ": timeout " + timeout.String() + " exceeded")
+	}
+	return err
+}
+
+// runHooks runs hooks of stage one by one. Stops on first failed hook
+func (exe *Executable) runHooks(ctx context.Context, stage string, hooks []Hook, env []string, logger *log.Logger) error {
+	for i := range hooks {
+		hook := &hooks[i]
+		logger.Println("Running", stage, "hook", hook.Command)
+		if err := hook.run(ctx, exe, env, logger); err != nil {
+			return fmt.Errorf("%v hook %v: %v", stage, hook.Command, err)
+		}
+	}
+	return nil
+}
+
+func validateHooks(stage string, hooks []Hook) error {
+	for i := range hooks {
+		if err := hooks[i].validate(); err != nil {
+			return fmt.Errorf("%v[%v]: %v", stage, i, err)
+		}
+	}
+	return nil
+}
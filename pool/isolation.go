@@ -0,0 +1,98 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Isolation - lightweight sandboxing of process by Linux namespaces and chroot
+type Isolation struct {
+	Namespaces    []string `yaml:"namespaces,omitempty"`      // Private namespaces: mount, pid, network, ipc, uts
+	Hostname      string   `yaml:"hostname,omitempty"`        // Host name in private uts namespace
+	Chroot        string   `yaml:"chroot,omitempty"`          // New root directory. Command and workdir are resolved inside it
+	ReadOnlyBinds []string `yaml:"read_only_binds,omitempty"` // Read-only bind mounts: source[:target]. Target is relative to chroot
+	PrivateTmp    bool     `yaml:"private_tmp,omitempty"`     // Mount empty tmpfs to /tmp
+}
+
+// bind mount for shim
+type shimBind struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// shimMounts - file system isolation applied by shim
+type shimMounts struct {
+	Chroot     string     `json:"chroot,omitempty"`
+	Binds      []shimBind `json:"binds,omitempty"`
+	PrivateTmp bool       `json:"private_tmp,omitempty"`
+	MountProc  bool       `json:"mount_proc,omitempty"`
+	Dir        string     `json:"dir,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+}
+
+func (iso *Isolation) validate() error {
+	for _, ns := range iso.Namespaces {
+		if _, ok := namespaces[ns]; !ok {
+			return fmt.Errorf("unknown namespace %v", ns)
+		}
+	}
+	if iso.Hostname != "" && !iso.hasNamespace("uts") {
+		return errors.New("hostname requires uts namespace")
+	}
+	if iso.Chroot != "" && !filepath.IsAbs(iso.Chroot) {
+		return errors.New("chroot should be absolute path")
+	}
+	_, err := iso.binds()
+	return err
+}
+
+func (iso *Isolation) hasNamespace(name string) bool {
+	for _, ns := range iso.Namespaces {
+		if ns == name {
+			return true
+		}
+	}
+	return false
+}
+
+// needMounts returns true if mount namespace and shim are required
+func (iso *Isolation) needMounts() bool {
+	return iso.Chroot != "" || len(iso.ReadOnlyBinds) > 0 || iso.PrivateTmp || iso.hasNamespace("pid") || iso.Hostname != ""
+}
+
+func (iso *Isolation) binds() ([]shimBind, error) {
+	var ans []shimBind
+	for _, item := range iso.ReadOnlyBinds {
+		parts := strings.SplitN(item, ":", 2)
+		bind := shimBind{Source: parts[0], Target: parts[0]}
+		if len(parts) == 2 {
+			bind.Target = parts[1]
+		}
+		if !filepath.IsAbs(bind.Source) || !filepath.IsAbs(bind.Target) {
+			return nil, fmt.Errorf("read_only_binds: %v: paths should be absolute", item)
+		}
+		ans = append(ans, bind)
+	}
+	return ans, nil
+}
+
+// shimMounts for shim. Proc file system is mounted again for private pid namespace
+func (iso *Isolation) shimMounts(workDir string) (*shimMounts, error) {
+	binds, err := iso.binds()
+	if err != nil {
+		return nil, err
+	}
+	m := &shimMounts{
+		Chroot:     iso.Chroot,
+		Binds:      binds,
+		PrivateTmp: iso.PrivateTmp,
+		MountProc:  iso.hasNamespace("pid"),
+		Hostname:   iso.Hostname,
+	}
+	if iso.Chroot != "" {
+		m.Dir = workDir
+	}
+	return m, nil
+}
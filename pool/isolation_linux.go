@@ -0,0 +1,126 @@
+package pool
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+var namespaces = map[string]uintptr{
+	"mount":   syscall.CLONE_NEWNS,
+	"pid":     syscall.CLONE_NEWPID,
+	"network": syscall.CLONE_NEWNET,
+	"ipc":     syscall.CLONE_NEWIPC,
+	"uts":     syscall.CLONE_NEWUTS,
+}
+
+// isolate sets namespaces of process. setAttrs should be called before
+func (exe *Executable) isolate(cmd *exec.Cmd) error {
+	iso := exe.Isolation
+	if iso == nil {
+		return nil
+	}
+	for _, ns := range iso.Namespaces {
+		cmd.SysProcAttr.Cloneflags |= namespaces[ns]
+	}
+	if iso.needMounts() {
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWNS
+	}
+	if iso.Chroot != "" {
+		// command and workdir are inside new root: resolved by shim
+		cmd.Err = nil
+		cmd.Path = exe.Command
+		cmd.Dir = ""
+	}
+	return nil
+}
+
+// apply mounts and chroot in private mount namespace
+func (m *shimMounts) apply() error {
+	// don't propagate mounts to parent namespace
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %v", err)
+	}
+	root := m.Chroot
+	if root == "" {
+		root = "/"
+	}
+	for _, bind := range m.Binds {
+		target := filepath.Join(root, bind.Target)
+		if err := mountPoint(bind.Source, target); err != nil {
+			return err
+		}
+		if err := syscall.Mount(bind.Source, target, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %v to %v: %v", bind.Source, target, err)
+		}
+		if err := syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, ""); err != nil {
+			return fmt.Errorf("remount %v read-only: %v", target, err)
+		}
+	}
+	if m.PrivateTmp {
+		target := filepath.Join(root, "tmp")
+		if err := os.MkdirAll(target, 0755); err != nil {
+			return err
+		}
+		if err := syscall.Mount("tmpfs", target, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount private /tmp: %v", err)
+		}
+	}
+	if m.MountProc {
+		target := filepath.Join(root, "proc")
+		if err := os.MkdirAll(target, 0555); err != nil {
+			return err
+		}
+		if err := syscall.Mount("proc", target, "proc", syscall.MS_NOSUID|syscall.MS_NOEXEC|syscall.MS_NODEV, ""); err != nil {
+			return fmt.Errorf("mount /proc: %v", err)
+		}
+	}
+	if m.Hostname != "" {
+		if err := syscall.Sethostname([]byte(m.Hostname)); err != nil {
+			return fmt.Errorf("set hostname: %v", err)
+		}
+	}
+	if m.Chroot != "" {
+		if err := syscall.Chroot(m.Chroot); err != nil {
+			return fmt.Errorf("chroot: %v", err)
+		}
+		dir := m.Dir
+		if dir == "" {
+			dir = "/"
+		}
+		if err := os.Chdir(dir); err != nil {
+			return fmt.Errorf("chdir: %v", err)
+		}
+	}
+	return nil
+}
+
+// resolve command inside new root
+func (m *shimMounts) resolve(path string) (string, error) {
+	if m.Chroot == "" || strings.Contains(path, "/") {
+		return path, nil
+	}
+	return exec.LookPath(path)
+}
+
+// mountPoint creates target of bind mount: directory or empty file like source
+func mountPoint(source, target string) error {
+	info, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return os.MkdirAll(target, 0755)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
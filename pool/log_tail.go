@@ -0,0 +1,72 @@
+package pool
+
+import (
+	"bytes"
+	"sync"
+)
+
+const defaultLogTailSize = 1000
+
+// logTail keeps last lines of process output
+type logTail struct {
+	lock  sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+func newLogTail(size int) *logTail {
+	return &logTail{lines: make([]string, size)}
+}
+
+func (lt *logTail) add(line string) {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	lt.lines[lt.next] = line
+	lt.next = (lt.next + 1) % len(lt.lines)
+	if lt.next == 0 {
+		lt.full = true
+	}
+}
+
+// Last n lines (all if n <= 0)
+func (lt *logTail) Last(n int) []string {
+	lt.lock.Lock()
+	defer lt.lock.Unlock()
+	var ans []string
+	if lt.full {
+		ans = append(ans, lt.lines[lt.next:]...)
+	}
+	ans = append(ans, lt.lines[:lt.next]...)
+	if n > 0 && len(ans) > n {
+		ans = ans[len(ans)-n:]
+	}
+	return ans
+}
+
+// writer for one stream: keeps incomplete line separately from other streams
+func (lt *logTail) writer() *tailWriter {
+	return &tailWriter{tail: lt}
+}
+
+type tailWriter struct {
+	tail    *logTail
+	partial []byte
+}
+
+func (tw *tailWriter) Write(data []byte) (int, error) {
+	tw.partial = append(tw.partial, data...)
+	for {
+		idx := bytes.IndexByte(tw.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		tw.tail.add(string(tw.partial[:idx]))
+		tw.partial = tw.partial[idx+1:]
+	}
+	if len(tw.partial) > maxProbeLineSize {
+		tw.tail.add(string(tw.partial))
+		tw.partial = nil
+	}
+	return len(data), nil
+}
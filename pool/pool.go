@@ -1,20 +1,45 @@
+// Package pool runs and supervises processes of services.
+//
+// Process attributes (rlimits, nice, umask, user/group switch...) and isolation are applied by shim on Linux:
+// supervisor starts its own binary which sets attributes and replaces itself by service command. Programs
+// that use pool as a library must call ShimMain at the beginning of main, before any other initialization:
+//
+//	func main() {
+//		pool.ShimMain()
+//		...
+//	}
+//
+// Otherwise such services fail to start with ErrShimNotInstalled.
 package pool
 
 import (
 	"context"
+	"errors"
 	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
 	"sync"
 )
 
 type Instance interface {
+	ID() string
+	Index() int
 	Stop()
+	State() State
+	Status() Status
+	Logs(n int) []string
+	Stats() Stats
+	Restart() error
+	Signal(sig os.Signal) error
+	Finished() bool
+	Stopped() bool
 	Config() *Executable
 	Supervisor() Supervisor
 	Pool() *Pool
 }
 
 type Supervisor interface {
-	Start(ctx context.Context, pool *Pool) Instance
+	Start(ctx context.Context, pool *Pool, index int) Instance
 	Config() *Executable
 }
 
@@ -28,7 +53,7 @@ type EventHandler interface {
 //  状态池
 //  实现EventHandler接口
 type Pool struct {
-	handlers     []EventHandler
+	handlers     []*watchedHandler
 	handlersLock sync.RWMutex
 
 	supervisors []Supervisor
@@ -40,78 +65,186 @@ type Pool struct {
 	doneInit sync.Once
 	done     chan struct{}
 
+	reloader     func() error
+	reloaderLock sync.RWMutex
+
 	terminating bool
 }
 
-// 全局信道 传递需要新启动的服务
-var NewServChan = make(chan Supervisor)
-
-// 全局信道 控制是否需要退出监听新服务的协程
-var HotReloadCloseChan = make(chan bool)
+// ErrReloadUnsupported - configuration of pool can't be reloaded (not loaded from files)
+var ErrReloadUnsupported = errors.New("configuration reload is not supported")
 
+// 按依赖关系的逆序停止所有实例: 被依赖的服务最后停止
 func (p *Pool) StopAll() {
-	wg := sync.WaitGroup{}
-	for _, sv := range p.grabInstances() {
-		wg.Add(1)
-		go func(sv Instance) {
-			defer wg.Done()
-			p.Stop(sv)
-		}(sv)
+	depth := p.dependencyDepth()
+	var layers [][]Instance
+	for _, in := range p.grabInstances() {
+		d := depth[in.Config().Name]
+		for len(layers) <= d {
+			layers = append(layers, nil)
+		}
+		layers[d] = append(layers[d], in)
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		wg := sync.WaitGroup{}
+		for _, in := range layers[i] {
+			wg.Add(1)
+			go func(in Instance) {
+				defer wg.Done()
+				p.Stop(in)
+			}(in)
+		}
+		wg.Wait()
 	}
-	wg.Wait()
 }
 
-//启动Pool里所有的Supervisor
+//  启动Pool里所有的Supervisor
+//  按依赖关系分层启动: 同一层的Supervisor并行启动，下一层在上一层全部启动后才开始
+//  ctx取消时终止整个Pool(按依赖关系的逆序停止)，实例本身不随ctx取消
 func (p *Pool) StartAll(ctx context.Context) {
 	if p.terminating {
 		return
 	}
-	wg := sync.WaitGroup{}
+	go p.terminateOnDone(ctx)
+	ctx = context.Background()
+	layers, err := DependencyLayers(p.Supervisors())
+	if err != nil {
+		log.Errorln("resolve service dependencies:", err)
+		layers = [][]Supervisor{p.Supervisors()}
+	}
+	// 被其他服务依赖的服务
+	required := make(map[string]bool)
 	for _, sv := range p.Supervisors() {
-		wg.Add(1)
-		go func(sv Supervisor) {
-			defer wg.Done()
-			p.Start(ctx, sv)
-		}(sv)
+		for _, dep := range sv.Config().DependsOn {
+			required[dep] = true
+		}
 	}
-
-	// 启动新Goroutine监听是否有新服务需要启动，并不需要关心是否启用了热重载，只需关心接收到退出信号退出协程
-	go p.startListenNewService(ctx, NewServChan, &wg)
-
-	wg.Wait()
-}
-
-// 监听是否有新服务需要启动，并在原Pool和WaitGroup中启动新服务
-func (p *Pool) startListenNewService(ctx context.Context, c <-chan Supervisor, wg *sync.WaitGroup) {
-	for {
-		select {
-		case <-HotReloadCloseChan:
-			log.Infoln("New service running goroutine exit...")
+	for _, layer := range layers {
+		if p.terminating {
 			return
-		case sv := <-c:
-			wg.Add(1)
+		}
+		layerWg := sync.WaitGroup{}
+		for _, sv := range layer {
+			layerWg.Add(1)
 			go func(sv Supervisor) {
-				defer wg.Done()
-				p.Start(ctx, sv)
-				log.Infoln("---> 服务", sv.Config().Name, "就绪 <---")
+				defer layerWg.Done()
+				for _, in := range p.StartReplicas(ctx, sv) {
+					if rn, ok := in.(*runnable); ok && required[sv.Config().Name] {
+						// 存在依赖此服务的服务时，需等待此服务就绪
+						rn.waitReady(p.Done())
+					}
+				}
 			}(sv)
 		}
+		layerWg.Wait()
 	}
 }
 
-//启动Pool里的一个Supervisor
+// InstanceID of replica of service
+func InstanceID(label string, index int) string {
+	return label + "-" + strconv.Itoa(index)
+}
+
+//  启动Pool里的一个Supervisor, ctx取消时实例停止
+//  新实例使用同一label下最小的空闲副本序号
 func (p *Pool) Start(ctx context.Context, sv Supervisor) Instance {
 	if p.terminating {
 		return nil
 	}
-
-	ins := sv.Start(ctx, p)
 	p.inLock.Lock()
+	defer p.inLock.Unlock()
+	used := make(map[int]bool)
+	for _, in := range p.instances {
+		if in.Config().Name == sv.Config().Name {
+			used[in.Index()] = true
+		}
+	}
+	index := 0
+	for used[index] {
+		index++
+	}
+	ins := sv.Start(ctx, p, index)
 	p.instances = append(p.instances, ins)
-	p.inLock.Unlock()
 	return ins
 }
 
+// StartReplicas starts configured amount of instances of supervisor
+func (p *Pool) StartReplicas(ctx context.Context, sv Supervisor) []Instance {
+	replicas := sv.Config().Replicas
+	if replicas < 1 {
+		replicas = 1
+	}
+	var ans []Instance
+	for i := 0; i < replicas; i++ {
+		if in := p.Start(ctx, sv); in != nil {
+			ans = append(ans, in)
+		}
+	}
+	return ans
+}
+
+// instances of service by label
+func (p *Pool) instancesOf(label string) []Instance {
+	var ans []Instance
+	for _, in := range p.Instances() {
+		if in.Config().Name == label {
+			ans = append(ans, in)
+		}
+	}
+	return ans
+}
+
+// Restart all instances of service. Running processes are stopped and started again without restart delay,
+// finished instances are started again with same index
+func (p *Pool) Restart(label string) error {
+	instances := p.instancesOf(label)
+	if len(instances) == 0 {
+		return errors.New("service " + label + " is not running")
+	}
+	for _, in := range instances {
+		if in.Restart() == ErrFinished {
+			p.Stop(in)
+			p.Start(context.Background(), in.Supervisor())
+		}
+	}
+	return nil
+}
+
+// Signal sends signal to processes of all running instances of service
+func (p *Pool) Signal(label string, sig os.Signal) error {
+	instances := p.instancesOf(label)
+	if len(instances) == 0 {
+		return errors.New("service " + label + " is not running")
+	}
+	var lastErr error
+	for _, in := range instances {
+		if err := in.Signal(sig); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// Find instance by ID
+func (p *Pool) Instance(id string) Instance {
+	for _, in := range p.Instances() {
+		if in.ID() == id {
+			return in
+		}
+	}
+	return nil
+}
+
+// terminate whole pool when parent context is done, so instances are stopped in reverse dependency order
+// instead of all at once
+func (p *Pool) terminateOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		p.Terminate()
+	case <-p.Done():
+	}
+}
+
 func (p *Pool) Stop(in Instance) {
 	in.Stop()
 	p.inLock.Lock()
@@ -134,17 +267,87 @@ func (p *Pool) Add(sv Supervisor) {
 	p.supervisors = append(p.supervisors, sv)
 }
 
+// Remove supervisor from pool and stop all it's instances
+func (p *Pool) Remove(sv Supervisor) {
+	p.svLock.Lock()
+	for i, v := range p.supervisors {
+		if v == sv {
+			p.supervisors = append(p.supervisors[:i], p.supervisors[i+1:]...)
+			break
+		}
+	}
+	p.svLock.Unlock()
+	for _, in := range p.Instances() {
+		if in.Supervisor() == sv {
+			p.Stop(in)
+		}
+	}
+}
+
+// SetReloader sets function that re-reads and applies configuration of pool. Used by Reload
+func (p *Pool) SetReloader(reload func() error) {
+	p.reloaderLock.Lock()
+	defer p.reloaderLock.Unlock()
+	p.reloader = reload
+}
+
+// Reload configuration of pool (SIGHUP, control socket, REST API)
+func (p *Pool) Reload() error {
+	p.reloaderLock.RLock()
+	reload := p.reloader
+	p.reloaderLock.RUnlock()
+	if reload == nil {
+		return ErrReloadUnsupported
+	}
+	return reload()
+}
+
 //  往Pool的handlers即[]EventHandler中添加新增的handler
 func (p *Pool) Watch(handler EventHandler) {
+	p.WatchPlugin("", handler, nil)
+}
+
+// WatchPlugin adds named handler (plugin) with result of it's preparation. Failed plugin doesn't receive events
+// but listed by Handlers
+func (p *Pool) WatchPlugin(name string, handler EventHandler, prepareErr error) {
 	p.handlersLock.Lock()
 	defer p.handlersLock.Unlock()
-	p.handlers = append(p.handlers, handler)
+	p.handlers = append(p.handlers, newWatchedHandler(name, handler, prepareErr))
+}
+
+// Unwatch removes handler from pool. Events are not delivered to handler after Unwatch returns: it waits for
+// events in progress, so it must not be called from event callback of same handler
+func (p *Pool) Unwatch(handler EventHandler) {
+	var removed []*watchedHandler
+	p.handlersLock.Lock()
+	var rest = make([]*watchedHandler, 0, len(p.handlers))
+	for _, wh := range p.handlers {
+		if wh.handler == handler {
+			removed = append(removed, wh)
+		} else {
+			rest = append(rest, wh)
+		}
+	}
+	p.handlers = rest
+	p.handlersLock.Unlock()
+	for _, wh := range removed {
+		wh.remove()
+	}
+}
+
+// Handlers returns information about all registered handlers (plugins)
+func (p *Pool) Handlers() []HandlerInfo {
+	var ans = make([]HandlerInfo, 0)
+	for _, wh := range p.cloneHandlers() {
+		ans = append(ans, wh.info)
+	}
+	return ans
 }
 
 //复制Pool中所有的Handler并返回对应切片
-func (p *Pool) cloneHandlers() []EventHandler {
+func (p *Pool) cloneHandlers() []*watchedHandler {
 	p.handlersLock.RLock()
-	var dest = make([]EventHandler, len(p.handlers))
+	var dest = make([]*watchedHandler, len(p.handlers))
 	copy(dest, p.handlers)
 	p.handlersLock.RUnlock()
 	return dest
@@ -177,29 +380,29 @@ func (p *Pool) grabInstances() []Instance {
 
 //调用Pool中所有的Handler即Plugin的OnSpawned方法
 func (p *Pool) OnSpawned(ctx context.Context, sv Instance) {
-	for _, handler := range p.cloneHandlers() {
-		handler.OnSpawned(ctx, sv)
+	for _, wh := range p.cloneHandlers() {
+		wh.deliver(func(handler EventHandler) { handler.OnSpawned(ctx, sv) })
 	}
 }
 
 //调用Pool中所有的Handler即Plugin的OnStarted方法
 func (p *Pool) OnStarted(ctx context.Context, sv Instance) {
-	for _, handler := range p.cloneHandlers() {
-		handler.OnStarted(ctx, sv)
+	for _, wh := range p.cloneHandlers() {
+		wh.deliver(func(handler EventHandler) { handler.OnStarted(ctx, sv) })
 	}
 }
 
 //调用Pool中所有的Handler即Plugin的OnStopped方法
 func (p *Pool) OnStopped(ctx context.Context, sv Instance, err error) {
-	for _, handler := range p.cloneHandlers() {
-		handler.OnStopped(ctx, sv, err)
+	for _, wh := range p.cloneHandlers() {
+		wh.deliver(func(handler EventHandler) { handler.OnStopped(ctx, sv, err) })
 	}
 }
 
 //调用Pool中所有的Handler即Plugin的OnFinished方法
 func (p *Pool) OnFinished(ctx context.Context, sv Instance) {
-	for _, handler := range p.cloneHandlers() {
-		handler.OnFinished(ctx, sv)
+	for _, wh := range p.cloneHandlers() {
+		wh.deliver(func(handler EventHandler) { handler.OnFinished(ctx, sv) })
 	}
 }
 
@@ -0,0 +1,78 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// spawnRecorder records spawned services
+type spawnRecorder struct {
+	lock    sync.Mutex
+	spawned map[string]bool
+}
+
+func (sr *spawnRecorder) OnSpawned(ctx context.Context, in Instance) {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	sr.spawned[in.Config().Name] = true
+}
+
+func (sr *spawnRecorder) isSpawned(label string) bool {
+	sr.lock.Lock()
+	defer sr.lock.Unlock()
+	return sr.spawned[label]
+}
+
+func (sr *spawnRecorder) OnStarted(ctx context.Context, in Instance) {}
+
+func (sr *spawnRecorder) OnStopped(ctx context.Context, in Instance, err error) {}
+
+func (sr *spawnRecorder) OnFinished(ctx context.Context, in Instance) {}
+
+func TestStartAllWaitsOnlyForDependencies(t *testing.T) {
+	service := func(label string, dependsOn ...string) *Executable {
+		return &Executable{
+			Name:           label,
+			Command:        "sleep",
+			Args:           []string{"10"},
+			DependsOn:      dependsOn,
+			Restart:        -1,
+			RestartTimeout: time.Second,
+			StopTimeout:    time.Second,
+		}
+	}
+	// leaf service in first layer never becomes ready: nothing depends on it
+	leaf := service("leaf")
+	leaf.Readiness = &Probe{File: "/nonexistent/monexec-test-ready", Interval: 100 * time.Millisecond}
+	db := service("db")
+	app := service("app", "db")
+
+	pl := &Pool{}
+	recorder := &spawnRecorder{spawned: make(map[string]bool)}
+	pl.Watch(recorder)
+	pl.Add(leaf)
+	pl.Add(db)
+	pl.Add(app)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go func() {
+		defer close(started)
+		pl.StartAll(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-pl.Done()
+		<-started
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !recorder.isSpawned("app") {
+		if time.Now().After(deadline) {
+			t.Fatal("dependent service is not started: waits for unrelated service")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,249 @@
+package pool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProbeInterval = 1 * time.Second
+	defaultProbeTimeout  = 1 * time.Second
+	defaultProbeFailures = 3
+	maxProbeLineSize     = 64 * 1024
+)
+
+// Probe describes check of service state. Only one kind of check (tcp, http, exec, file, log) should be set
+type Probe struct {
+	TCP          string        `yaml:"tcp,omitempty"`               // Address (host:port) that should accept connections
+	HTTP         string        `yaml:"http,omitempty"`              // URL for GET request
+	Status       int           `yaml:"status,omitempty"`            // Expected HTTP status. If not set - any 2xx or 3xx
+	Exec         []string      `yaml:"exec,omitempty"`              // Command and arguments. Zero exit code means success. Env and workdir same as for service
+	File         string        `yaml:"file,omitempty"`              // File that should exist. If not absolute - relative to workdir
+	Log          string        `yaml:"log,omitempty"`               // Regular expression for line of service output
+	InitialDelay time.Duration `yaml:"initial_delay,omitempty"`     // Delay before first check
+	Interval     time.Duration `yaml:"interval,omitempty"`          // Interval between checks. Default 1s
+	Timeout      time.Duration `yaml:"timeout,omitempty"`           // Timeout for one check. Default 1s
+	Failures     int           `yaml:"failure_threshold,omitempty"` // Liveness only: failed checks in a row before restart. Default 3
+}
+
+// HealthError is a stop reason of process that failed liveness probe
+type HealthError struct {
+	Failures int   // failed checks in a row
+	Last     error // error of last check
+}
+
+func (e *HealthError) Error() string {
+	return fmt.Sprintf("liveness probe failed %v times: %v", e.Failures, e.Last)
+}
+
+func (p *Probe) validate(liveness bool) error {
+	checks := 0
+	for _, defined := range []bool{p.TCP != "", p.HTTP != "", len(p.Exec) > 0, p.File != "", p.Log != ""} {
+		if defined {
+			checks++
+		}
+	}
+	if checks != 1 {
+		return errors.New("exactly one of tcp, http, exec, file or log should be defined")
+	}
+	if liveness && p.Log != "" {
+		return errors.New("log check is not supported for liveness")
+	}
+	if p.Log != "" {
+		if _, err := regexp.Compile(p.Log); err != nil {
+			return fmt.Errorf("parse log pattern: %v", err)
+		}
+	}
+	return nil
+}
+
+// prober holds state of probe for one process run
+type prober struct {
+	probe *Probe
+	exe   *Executable
+	env   []string
+	log   *log.Logger
+
+	logPattern *regexp.Regexp
+	lock       sync.Mutex
+	buffer     []byte
+	matched    bool
+}
+
+func newProber(probe *Probe, exe *Executable, env []string, logger *log.Logger) (*prober, error) {
+	pr := &prober{probe: probe, exe: exe, env: env, log: logger}
+	if probe.Log != "" {
+		re, err := regexp.Compile(probe.Log)
+		if err != nil {
+			return nil, fmt.Errorf("parse log probe pattern: %v", err)
+		}
+		pr.logPattern = re
+	}
+	return pr, nil
+}
+
+// Write scans service output for log probe
+func (pr *prober) Write(data []byte) (int, error) {
+	if pr.logPattern == nil {
+		return len(data), nil
+	}
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	if pr.matched {
+		return len(data), nil
+	}
+	pr.buffer = append(pr.buffer, data...)
+	for {
+		idx := bytes.IndexByte(pr.buffer, '\n')
+		if idx < 0 {
+			break
+		}
+		line := pr.buffer[:idx]
+		pr.buffer = pr.buffer[idx+1:]
+		if pr.logPattern.Match(line) {
+			pr.matched = true
+			pr.buffer = nil
+			break
+		}
+	}
+	if len(pr.buffer) > maxProbeLineSize {
+		pr.buffer = pr.buffer[len(pr.buffer)-maxProbeLineSize:]
+	}
+	return len(data), nil
+}
+
+func (pr *prober) interval() time.Duration {
+	if pr.probe.Interval <= 0 {
+		return defaultProbeInterval
+	}
+	return pr.probe.Interval
+}
+
+func (pr *prober) timeout() time.Duration {
+	if pr.probe.Timeout <= 0 {
+		return defaultProbeTimeout
+	}
+	return pr.probe.Timeout
+}
+
+// check runs one probe attempt
+func (pr *prober) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pr.timeout())
+	defer cancel()
+	probe := pr.probe
+	switch {
+	case probe.TCP != "":
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", probe.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case probe.HTTP != "":
+		req, err := http.NewRequest(http.MethodGet, probe.HTTP, nil)
+		if err != nil {
+			return err
+		}
+		res, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if probe.Status != 0 && res.StatusCode != probe.Status {
+			return fmt.Errorf("unexpected HTTP status %v", res.StatusCode)
+		}
+		if probe.Status == 0 && (res.StatusCode < 200 || res.StatusCode >= 400) {
+			return fmt.Errorf("unexpected HTTP status %v", res.StatusCode)
+		}
+		return nil
+	case len(probe.Exec) > 0:
+		cmd := exec.CommandContext(ctx, probe.Exec[0], probe.Exec[1:]...)
+		cmd.Env = pr.env
+		cmd.Dir = pr.exe.WorkDir
+		return cmd.Run()
+	case probe.File != "":
+		file := probe.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(pr.exe.WorkDir, file)
+		}
+		_, err := os.Stat(file)
+		return err
+	case pr.logPattern != nil:
+		pr.lock.Lock()
+		defer pr.lock.Unlock()
+		if !pr.matched {
+			return errors.New("log line not found yet")
+		}
+		return nil
+	}
+	return errors.New("probe has no check defined")
+}
+
+func (pr *prober) failures() int {
+	if pr.probe.Failures <= 0 {
+		return defaultProbeFailures
+	}
+	return pr.probe.Failures
+}
+
+// watch periodically checks probe and returns HealthError after too many failures in a row.
+// Returns nil if context done
+func (pr *prober) watch(ctx context.Context) error {
+	select {
+	case <-time.After(pr.probe.InitialDelay):
+	case <-ctx.Done():
+		return nil
+	}
+	failed := 0
+	for {
+		err := pr.check(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil {
+			failed = 0
+		} else {
+			failed++
+			pr.log.Println("liveness check failed:", err)
+			if failed >= pr.failures() {
+				return &HealthError{Failures: failed, Last: err}
+			}
+		}
+		select {
+		case <-time.After(pr.interval()):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// wait until probe succeeded or context done
+func (pr *prober) wait(ctx context.Context) error {
+	select {
+	case <-time.After(pr.probe.InitialDelay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for {
+		err := pr.check(ctx)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-time.After(pr.interval()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
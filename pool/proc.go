@@ -0,0 +1,11 @@
+// +build !linux
+
+package pool
+
+import "errors"
+
+var errSamplingUnsupported = errors.New("resources sampling is not supported on this platform")
+
+func sampleGroup(pgid int) (*Usage, error) {
+	return nil, errSamplingUnsupported
+}
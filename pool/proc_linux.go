@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const clockTicks = 100 // USER_HZ
+
+var errSamplingUnsupported = errors.New("resources sampling is not supported on this platform")
+
+// sampleGroup collects usage of all processes in process group from /proc
+func sampleGroup(pgid int) (*Usage, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	usage := &Usage{Time: time.Now()}
+	pageSize := uint64(os.Getpagesize())
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		dir := filepath.Join("/proc", entry.Name())
+		data, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+		if err != nil {
+			continue // process already finished
+		}
+		// command name in parentheses may contain spaces
+		idx := strings.LastIndexByte(string(data), ')')
+		if idx < 0 {
+			continue
+		}
+		fields := strings.Fields(string(data[idx+1:])) // first field is state (3rd in stat)
+		if len(fields) < 22 {
+			continue
+		}
+		if group, _ := strconv.Atoi(fields[2]); group != pgid && pid != pgid {
+			continue
+		}
+		utime, _ := strconv.ParseUint(fields[11], 10, 64)
+		stime, _ := strconv.ParseUint(fields[12], 10, 64)
+		threads, _ := strconv.Atoi(fields[17])
+		rss, _ := strconv.ParseUint(fields[21], 10, 64)
+
+		usage.Processes++
+		usage.CPUSeconds += float64(utime+stime) / clockTicks
+		usage.Threads += threads
+		usage.RSS += rss * pageSize
+		if fds, err := ioutil.ReadDir(filepath.Join(dir, "fd")); err == nil {
+			usage.OpenFiles += len(fds)
+		}
+		read, written := readIO(filepath.Join(dir, "io"))
+		usage.ReadBytes += read
+		usage.WriteBytes += written
+	}
+	if usage.Processes == 0 {
+		return nil, errors.New("no processes in group")
+	}
+	return usage, nil
+}
+
+func readIO(fileName string) (read, written uint64) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "read_bytes:":
+			read, _ = strconv.ParseUint(fields[1], 10, 64)
+		case "write_bytes:":
+			written, _ = strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return read, written
+}
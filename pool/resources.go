@@ -0,0 +1,129 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const defaultCgroupRoot = "/sys/fs/cgroup/monexec"
+
+// Resources - limits applied through cgroup v2. Every instance gets own cgroup <root>/<instance ID>
+type Resources struct {
+	Root       string `yaml:"root,omitempty"`        // Parent cgroup for services (must be delegated). Default /sys/fs/cgroup/monexec
+	MemoryMax  string `yaml:"memory_max,omitempty"`  // Hard memory limit in bytes (suffixes K, M, G, T). OOM killer is used above it
+	MemoryHigh string `yaml:"memory_high,omitempty"` // Memory throttling limit in bytes (suffixes K, M, G, T)
+	CPUWeight  int    `yaml:"cpu_weight,omitempty"`  // Relative CPU share, 1-10000. Kernel default 100
+	CPUQuota   string `yaml:"cpu_quota,omitempty"`   // CPU time limit in percents of one CPU (150% - one and half CPU)
+	PidsMax    int    `yaml:"pids_max,omitempty"`    // Maximum number of processes and threads
+	IOWeight   int    `yaml:"io_weight,omitempty"`   // Relative IO share, 1-10000. Kernel default 100
+}
+
+type cgroupSetting struct {
+	controller string
+	file       string
+	value      string
+}
+
+func (r *Resources) validate() error {
+	_, err := r.settings()
+	return err
+}
+
+// settings converts limits to values of cgroup files
+func (r *Resources) settings() ([]cgroupSetting, error) {
+	var ans []cgroupSetting
+	if r.MemoryMax != "" {
+		v, err := parseSize(r.MemoryMax)
+		if err != nil {
+			return nil, fmt.Errorf("memory_max: %v", err)
+		}
+		ans = append(ans, cgroupSetting{"memory", "memory.max", v})
+	}
+	if r.MemoryHigh != "" {
+		v, err := parseSize(r.MemoryHigh)
+		if err != nil {
+			return nil, fmt.Errorf("memory_high: %v", err)
+		}
+		ans = append(ans, cgroupSetting{"memory", "memory.high", v})
+	}
+	if r.CPUWeight != 0 {
+		if r.CPUWeight < 1 || r.CPUWeight > 10000 {
+			return nil, errors.New("cpu_weight should be in range 1-10000")
+		}
+		ans = append(ans, cgroupSetting{"cpu", "cpu.weight", strconv.Itoa(r.CPUWeight)})
+	}
+	if r.CPUQuota != "" {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(r.CPUQuota), "%"), 64)
+		if err != nil || percent <= 0 {
+			return nil, fmt.Errorf("cpu_quota: invalid value %v", r.CPUQuota)
+		}
+		// period 100ms: 1% is 1ms
+		ans = append(ans, cgroupSetting{"cpu", "cpu.max", strconv.Itoa(int(percent*1000)) + " 100000"})
+	}
+	if r.PidsMax != 0 {
+		if r.PidsMax < 0 {
+			return nil, errors.New("pids_max should be positive")
+		}
+		ans = append(ans, cgroupSetting{"pids", "pids.max", strconv.Itoa(r.PidsMax)})
+	}
+	if r.IOWeight != 0 {
+		if r.IOWeight < 1 || r.IOWeight > 10000 {
+			return nil, errors.New("io_weight should be in range 1-10000")
+		}
+		ans = append(ans, cgroupSetting{"io", "io.weight", "default " + strconv.Itoa(r.IOWeight)})
+	}
+	return ans, nil
+}
+
+func (r *Resources) root() string {
+	if r.Root == "" {
+		return defaultCgroupRoot
+	}
+	return r.Root
+}
+
+// parseSize parses size like 512M to bytes. `max` means no limit
+func parseSize(value string) (string, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if value == "MAX" {
+		return "max", nil
+	}
+	value = strings.TrimSuffix(strings.TrimSuffix(value, "B"), "I")
+	multiplier := int64(1)
+	if n := len(value); n > 0 {
+		switch value[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			value = value[:n-1]
+		}
+	}
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || v <= 0 {
+		return "", errors.New("invalid size")
+	}
+	return strconv.FormatInt(v*multiplier, 10), nil
+}
+
+// oomKilled - process (or one of it's children) was killed by OOM killer of cgroup
+type oomKilled struct {
+	err error
+}
+
+func (e *oomKilled) Error() string {
+	if e.err == nil {
+		return "killed by OOM killer"
+	}
+	return "killed by OOM killer: " + e.err.Error()
+}
+
+func (e *oomKilled) Unwrap() error { return e.err }
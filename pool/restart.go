@@ -0,0 +1,131 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultBackoffMax        = 5 * time.Minute
+	defaultBackoffMultiplier = 2
+	defaultBackoffResetAfter = 1 * time.Minute
+	defaultBackoffCrashLoop  = 5
+)
+
+// Backoff - restart policy with exponentially growing delay between restarts
+type Backoff struct {
+	Initial    time.Duration `yaml:"initial,omitempty"`     // First delay. If not set - restart_delay used
+	Max        time.Duration `yaml:"max,omitempty"`         // Upper limit of delay. Default 5m
+	Multiplier float64       `yaml:"multiplier,omitempty"`  // Delay multiplier after each short run. Default 2
+	Jitter     float64       `yaml:"jitter,omitempty"`      // Random part of delay in fractions (0.1 means +-10%)
+	ResetAfter time.Duration `yaml:"reset_after,omitempty"` // Run longer than this resets delay and restarts counter. Default 1m
+	CrashLoop  int           `yaml:"crash_loop,omitempty"`  // Short runs in a row to consider service flapping. Default 5
+}
+
+func (b *Backoff) validate() error {
+	if b.Jitter < 0 || b.Jitter >= 1 {
+		return fmt.Errorf("jitter should be in range [0, 1), got %v", b.Jitter)
+	}
+	if b.Multiplier != 0 && b.Multiplier < 1 {
+		return fmt.Errorf("multiplier should be at least 1, got %v", b.Multiplier)
+	}
+	return nil
+}
+
+// restartTracker decides when and whether instance should be restarted
+type restartTracker struct {
+	exe       *Executable
+	log       *log.Logger
+	left      int // restarts left, -1 means infinite
+	shortRuns int // runs shorter than reset window in a row
+	delay     time.Duration
+}
+
+func newRestartTracker(exe *Executable, logger *log.Logger) *restartTracker {
+	rt := &restartTracker{exe: exe, log: logger}
+	rt.reset()
+	return rt
+}
+
+func (rt *restartTracker) reset() {
+	rt.left = rt.exe.Restart
+	rt.shortRuns = 0
+	rt.delay = rt.initialDelay()
+}
+
+func (rt *restartTracker) initialDelay() time.Duration {
+	if b := rt.exe.Backoff; b != nil && b.Initial > 0 {
+		return b.Initial
+	}
+	return rt.exe.RestartTimeout
+}
+
+// stopped registers finished run
+func (rt *restartTracker) stopped(runTime time.Duration) {
+	b := rt.exe.Backoff
+	if b == nil {
+		return
+	}
+	resetAfter := b.ResetAfter
+	if resetAfter <= 0 {
+		resetAfter = defaultBackoffResetAfter
+	}
+	if runTime >= resetAfter {
+		if rt.shortRuns > 0 || rt.left != rt.exe.Restart {
+			rt.log.Println("stable for", runTime, "- restart counter reset")
+		}
+		rt.reset()
+		return
+	}
+	rt.shortRuns++
+}
+
+// crashLoop returns true if service is restarting too often
+func (rt *restartTracker) crashLoop() bool {
+	b := rt.exe.Backoff
+	if b == nil {
+		return false
+	}
+	threshold := b.CrashLoop
+	if threshold <= 0 {
+		threshold = defaultBackoffCrashLoop
+	}
+	return rt.shortRuns >= threshold
+}
+
+// next returns delay before restart or false if restarts budget exhausted
+func (rt *restartTracker) next() (time.Duration, bool) {
+	if rt.left != -1 {
+		if rt.left <= 0 {
+			return 0, false
+		}
+		rt.left--
+	}
+	b := rt.exe.Backoff
+	if b == nil {
+		return rt.exe.RestartTimeout, true
+	}
+	delay := rt.delay
+	if b.Jitter > 0 {
+		delay += time.Duration(float64(delay) * b.Jitter * (2*rand.Float64() - 1))
+	}
+
+	multiplier := b.Multiplier
+	if multiplier <= 0 {
+		multiplier = defaultBackoffMultiplier
+	}
+	max := b.Max
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	rt.delay = time.Duration(float64(rt.delay) * multiplier)
+	if rt.delay > max {
+		rt.delay = max
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay, true
+}
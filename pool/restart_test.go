@@ -0,0 +1,129 @@
+package pool
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestRestartTrackerNext(t *testing.T) {
+	cases := []struct {
+		name    string
+		exe     Executable
+		delays  []time.Duration // expected delays, then restarts are exhausted
+		limited bool            // restarts budget is exhausted after delays
+	}{
+		{
+			name:   "fixed delay infinite",
+			exe:    Executable{Restart: -1, RestartTimeout: time.Second},
+			delays: []time.Duration{time.Second, time.Second, time.Second},
+		},
+		{
+			name:    "fixed delay limited",
+			exe:     Executable{Restart: 2, RestartTimeout: time.Second},
+			delays:  []time.Duration{time.Second, time.Second},
+			limited: true,
+		},
+		{
+			name:    "no restarts",
+			exe:     Executable{Restart: 0, RestartTimeout: time.Second},
+			limited: true,
+		},
+		{
+			name:   "backoff initial from restart delay",
+			exe:    Executable{Restart: -1, RestartTimeout: time.Second, Backoff: &Backoff{}},
+			delays: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			name:   "backoff multiplier and max",
+			exe:    Executable{Restart: -1, Backoff: &Backoff{Initial: time.Second, Multiplier: 3, Max: 10 * time.Second}},
+			delays: []time.Duration{time.Second, 3 * time.Second, 9 * time.Second, 10 * time.Second, 10 * time.Second},
+		},
+		{
+			name:    "backoff limited",
+			exe:     Executable{Restart: 2, Backoff: &Backoff{Initial: time.Second}},
+			delays:  []time.Duration{time.Second, 2 * time.Second},
+			limited: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			exe := tc.exe
+			rt := newRestartTracker(&exe, log.New(ioutil.Discard, "", 0))
+			for i, expected := range tc.delays {
+				delay, ok := rt.next()
+				if !ok {
+					t.Fatalf("restart %v: unexpected end of restarts", i)
+				}
+				if delay != expected {
+					t.Fatalf("restart %v: expected delay %v, got %v", i, expected, delay)
+				}
+			}
+			if _, ok := rt.next(); ok == tc.limited {
+				t.Fatalf("expected restarts exhausted: %v", tc.limited)
+			}
+		})
+	}
+}
+
+func TestRestartTrackerJitter(t *testing.T) {
+	exe := Executable{Restart: -1, Backoff: &Backoff{Initial: time.Second, Multiplier: 1, Jitter: 0.5}}
+	rt := newRestartTracker(&exe, log.New(ioutil.Discard, "", 0))
+	for i := 0; i < 100; i++ {
+		delay, _ := rt.next()
+		if delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("delay %v is out of jitter range", delay)
+		}
+	}
+}
+
+func TestRestartTrackerStopped(t *testing.T) {
+	exe := Executable{Restart: 3, Backoff: &Backoff{Initial: time.Second, ResetAfter: time.Minute, CrashLoop: 2}}
+	rt := newRestartTracker(&exe, log.New(ioutil.Discard, "", 0))
+
+	rt.stopped(time.Second)
+	rt.next()
+	if rt.crashLoop() {
+		t.Fatal("crash loop after one short run")
+	}
+	rt.stopped(time.Second)
+	rt.next()
+	if !rt.crashLoop() {
+		t.Fatal("no crash loop after two short runs")
+	}
+	if rt.left != 1 {
+		t.Fatalf("expected 1 restart left, got %v", rt.left)
+	}
+
+	rt.stopped(time.Minute)
+	if rt.crashLoop() {
+		t.Fatal("crash loop after stable run")
+	}
+	if rt.left != 3 {
+		t.Fatalf("expected restarts counter reset to 3, got %v", rt.left)
+	}
+	if delay, _ := rt.next(); delay != time.Second {
+		t.Fatalf("expected delay reset to 1s, got %v", delay)
+	}
+}
+
+func TestBackoffValidate(t *testing.T) {
+	cases := []struct {
+		backoff Backoff
+		valid   bool
+	}{
+		{Backoff{}, true},
+		{Backoff{Jitter: 0.99, Multiplier: 1}, true},
+		{Backoff{Jitter: 1}, false},
+		{Backoff{Jitter: 3}, false},
+		{Backoff{Jitter: -0.1}, false},
+		{Backoff{Multiplier: 0.5}, false},
+		{Backoff{Multiplier: -2}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.backoff.validate(); (err == nil) != tc.valid {
+			t.Errorf("%+v: expected valid %v, got error %v", tc.backoff, tc.valid, err)
+		}
+	}
+}
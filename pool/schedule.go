@@ -0,0 +1,317 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Overlap policies of scheduled services: what to do if previous run is still active at next run time
+const (
+	OverlapSkip    = "skip"    // skip new run (default)
+	OverlapQueue   = "queue"   // start new run right after previous one (at most one run is queued)
+	OverlapReplace = "replace" // stop previous run and start new one
+)
+
+var (
+	ErrMaxRuntime = errors.New("max runtime exceeded")           // stop reason of scheduled run that took too long
+	ErrReplaced   = errors.New("replaced by next scheduled run") // stop reason of run stopped by overlap policy replace
+)
+
+// schedule calculates time of next run
+type schedule interface {
+	next(after time.Time) time.Time
+}
+
+// every - fixed interval (@every 5m)
+type every time.Duration
+
+func (e every) next(after time.Time) time.Time { return after.Add(time.Duration(e)) }
+
+// cronSchedule - standard cron expression: minute hour day-of-month month day-of-week
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit sets
+	domAny, dowAny                bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	monthNames = map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}
+	dowNames   = map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}
+)
+
+// parseSchedule parses cron expression, descriptor (@daily) or fixed interval (@every 5m)
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, errors.New("interval should be positive")
+		}
+		return every(d), nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %v", len(fields))
+	}
+	var cs cronSchedule
+	var err error
+	if cs.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("minute: %v", err)
+	}
+	if cs.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("hour: %v", err)
+	}
+	if cs.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("day of month: %v", err)
+	}
+	if cs.month, err = parseCronField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("month: %v", err)
+	}
+	if cs.dow, err = parseCronField(fields[4], 0, 7, dowNames); err != nil {
+		return nil, fmt.Errorf("day of week: %v", err)
+	}
+	if cs.dow&(1<<7) != 0 { // 7 is sunday too
+		cs.dow |= 1
+	}
+	// like in Vixie cron any field starting with * (i.e. */2) doesn't restrict day
+	cs.domAny = strings.HasPrefix(fields[2], "*") || fields[2] == "?"
+	cs.dowAny = strings.HasPrefix(fields[4], "*") || fields[4] == "?"
+	if cs.next(time.Now()).IsZero() {
+		return nil, errors.New("schedule never matches")
+	}
+	return &cs, nil
+}
+
+// parseCronField parses comma-separated list of values, ranges (1-5), steps (*/10, 0-30/5) and names
+func parseCronField(field string, min, max int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			v, err := strconv.Atoi(part[idx+1:])
+			if err != nil || v <= 0 {
+				return 0, fmt.Errorf("invalid step in %v", part)
+			}
+			step = v
+			part = part[:idx]
+		}
+		from, to := min, max
+		if part != "*" && part != "?" {
+			bounds := strings.SplitN(part, "-", 2)
+			v, err := parseCronValue(bounds[0], names)
+			if err != nil {
+				return 0, err
+			}
+			from, to = v, v
+			if len(bounds) == 2 {
+				if to, err = parseCronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				to = max // 5/10 means from 5 to max with step 10
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%v is out of range %v-%v", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %v", value)
+	}
+	return v, nil
+}
+
+func (cs *cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domAny || cs.dowAny {
+		return domMatch && dowMatch
+	}
+	// both restricted: any of them
+	return domMatch || dowMatch
+}
+
+// next matched minute after time. Zero time if nothing found in 5 years
+func (cs *cronSchedule) next(after time.Time) time.Time {
+	loc := after.Location()
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if cs.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !cs.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if cs.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if cs.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (exe *Executable) validateSchedule() error {
+	if exe.Schedule == "" {
+		return nil
+	}
+	if _, err := parseSchedule(exe.Schedule); err != nil {
+		return fmt.Errorf("schedule: %v", err)
+	}
+	switch exe.Overlap {
+	case "", OverlapSkip, OverlapQueue, OverlapReplace:
+	default:
+		return fmt.Errorf("unknown overlap policy %v", exe.Overlap)
+	}
+	return nil
+}
+
+// runScheduled runs process by schedule instead of restart loop
+func (rn *runnable) runScheduled(ctx context.Context) {
+	sched, err := parseSchedule(rn.Executable.Schedule)
+	if err != nil {
+		rn.log.Println("invalid schedule:", err)
+		return
+	}
+	// dependent services should not wait for the first run
+	rn.readyOnce.Do(func() { close(rn.ready) })
+	next := sched.next(time.Now())
+	for {
+		rn.setNextRun(next)
+		rn.setState(false, StateScheduled)
+		rn.log.Println("next run at", next.Format(time.RFC3339))
+		select {
+		case <-time.After(time.Until(next)):
+			next = sched.next(time.Now())
+		case <-rn.restartRequest:
+			rn.log.Println("run requested")
+		case <-ctx.Done():
+			rn.log.Println("instance done:", ctx.Err())
+			return
+		}
+		again := true
+		for again {
+			next, again = rn.runOnce(ctx, sched, next)
+		}
+		if ctx.Err() != nil {
+			rn.log.Println("instance done:", ctx.Err())
+			return
+		}
+	}
+}
+
+// runOnce runs process and applies overlap policy for runs scheduled while process is active.
+// Returns time of next run and true if process should be started again right now
+func (rn *runnable) runOnce(ctx context.Context, sched schedule, next time.Time) (time.Time, bool) {
+	exe := rn.Executable
+	runCtx, cancel := context.WithCancel(ctx)
+	if exe.MaxRuntime > 0 {
+		runCtx, cancel = context.WithTimeout(ctx, exe.MaxRuntime)
+	}
+	defer cancel()
+	rn.setLastRun(time.Now())
+	rn.setNextRun(next)
+	rn.setState(true, StateStarting)
+	done := make(chan error, 1)
+	go func() {
+		done <- exe.run(runCtx, rn)
+	}()
+	var (
+		again    bool
+		replaced bool
+		err      error
+	)
+WAIT:
+	for {
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case err = <-done:
+			timer.Stop()
+			break WAIT
+		case <-timer.C:
+			next = sched.next(time.Now())
+			rn.setNextRun(next)
+			switch exe.Overlap {
+			case OverlapQueue:
+				rn.log.Println("previous run is still active: next run queued")
+				again = true
+			case OverlapReplace:
+				rn.log.Println("previous run is still active: replacing")
+				again, replaced = true, true
+				cancel()
+			default:
+				rn.log.Println("previous run is still active: run skipped")
+			}
+		}
+	}
+	switch {
+	case err == ErrRestartRequested:
+		again = true
+	case ctx.Err() != nil:
+		err = exe.exitReason(err)
+	case replaced:
+		err = ErrReplaced
+	case runCtx.Err() == context.DeadlineExceeded:
+		err = ErrMaxRuntime
+	default:
+		err = exe.exitReason(err)
+	}
+	rn.runFinished(err)
+	if err != nil {
+		rn.log.Println("run finished with error:", err)
+	} else {
+		rn.log.Println("run finished")
+	}
+	rn.setState(false, StateScheduled)
+	rn.pool.OnStopped(ctx, rn, err)
+	return next, again && ctx.Err() == nil
+}
+
+func (rn *runnable) setNextRun(t time.Time) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.nextRun = t
+}
+
+func (rn *runnable) setLastRun(t time.Time) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.lastRun = t
+}
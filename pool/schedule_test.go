@@ -0,0 +1,152 @@
+package pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	cases := []struct {
+		field    string
+		min, max int
+		names    map[string]int
+		values   []int
+		err      bool
+	}{
+		{field: "*", min: 0, max: 5, values: []int{0, 1, 2, 3, 4, 5}},
+		{field: "?", min: 1, max: 3, values: []int{1, 2, 3}},
+		{field: "3", min: 0, max: 59, values: []int{3}},
+		{field: "1,5,7", min: 0, max: 59, values: []int{1, 5, 7}},
+		{field: "10-13", min: 0, max: 59, values: []int{10, 11, 12, 13}},
+		{field: "*/15", min: 0, max: 59, values: []int{0, 15, 30, 45}},
+		{field: "0-30/10", min: 0, max: 59, values: []int{0, 10, 20, 30}},
+		{field: "5/20", min: 0, max: 59, values: []int{5, 25, 45}},
+		{field: "1-3,10", min: 0, max: 59, values: []int{1, 2, 3, 10}},
+		{field: "jan,MAR-apr", min: 1, max: 12, names: monthNames, values: []int{1, 3, 4}},
+		{field: "mon-fri", min: 0, max: 7, names: dowNames, values: []int{1, 2, 3, 4, 5}},
+		{field: "60", min: 0, max: 59, err: true},
+		{field: "0", min: 1, max: 31, err: true},
+		{field: "5-1", min: 0, max: 59, err: true},
+		{field: "*/0", min: 0, max: 59, err: true},
+		{field: "*/x", min: 0, max: 59, err: true},
+		{field: "abc", min: 0, max: 59, err: true},
+		{field: "jan", min: 1, max: 12, err: true},
+		{field: "1,", min: 0, max: 59, err: true},
+	}
+	for _, tc := range cases {
+		bits, err := parseCronField(tc.field, tc.min, tc.max, tc.names)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%v: expected error", tc.field)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", tc.field, err)
+			continue
+		}
+		var expected uint64
+		for _, v := range tc.values {
+			expected |= 1 << uint(v)
+		}
+		if bits != expected {
+			t.Errorf("%v: expected %b, got %b", tc.field, expected, bits)
+		}
+	}
+}
+
+func TestParseScheduleErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every",
+		"@every 0s",
+		"@every -1m",
+		"@every soon",
+		"@sometimes",
+		"61 * * * *",
+		"* 24 * * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"0 0 30 feb *",
+		"0 0 31 apr,jun,sep,nov *",
+	} {
+		if _, err := parseSchedule(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	// 2021-03-10 is wednesday
+	after := time.Date(2021, 3, 10, 14, 37, 25, 0, time.UTC)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2021, month, day, hour, min, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		spec string
+		next []time.Time // consequent runs
+	}{
+		{"@every 90s", []time.Time{after.Add(90 * time.Second), after.Add(180 * time.Second)}},
+		{"* * * * *", []time.Time{at(3, 10, 14, 38), at(3, 10, 14, 39)}},
+		{"*/15 * * * *", []time.Time{at(3, 10, 14, 45), at(3, 10, 15, 0), at(3, 10, 15, 15)}},
+		{"30 9 * * *", []time.Time{at(3, 11, 9, 30), at(3, 12, 9, 30)}},
+		{"0 0 1 * *", []time.Time{at(4, 1, 0, 0), at(5, 1, 0, 0)}},
+		{"@hourly", []time.Time{at(3, 10, 15, 0), at(3, 10, 16, 0)}},
+		{"@daily", []time.Time{at(3, 11, 0, 0)}},
+		{"@weekly", []time.Time{at(3, 14, 0, 0), at(3, 21, 0, 0)}},
+		{"@monthly", []time.Time{at(4, 1, 0, 0)}},
+		{"@yearly", []time.Time{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		// weekdays only: wednesday afternoon -> thursday, friday, monday
+		{"0 8 * * mon-fri", []time.Time{at(3, 11, 8, 0), at(3, 12, 8, 0), at(3, 15, 8, 0)}},
+		// 7 is sunday too
+		{"0 0 * * 7", []time.Time{at(3, 14, 0, 0)}},
+		// both day of month and day of week restricted: any of them matches
+		{"0 12 13 * fri", []time.Time{at(3, 12, 12, 0), at(3, 13, 12, 0), at(3, 19, 12, 0)}},
+		// day of month with step from *: both must match (odd fridays)
+		{"0 12 */2 * fri", []time.Time{at(3, 19, 12, 0), at(4, 9, 12, 0)}},
+		// day of month restricted, day of week any: only day of month
+		{"0 12 13 * *", []time.Time{at(3, 13, 12, 0), at(4, 13, 12, 0)}},
+		// skip months without 31st day
+		{"0 0 31 * *", []time.Time{at(3, 31, 0, 0), at(5, 31, 0, 0), at(7, 31, 0, 0)}},
+		// leap day
+		{"0 0 29 feb *", []time.Time{time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)}},
+	}
+	for _, tc := range cases {
+		sched, err := parseSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		prev := after
+		for i, expected := range tc.next {
+			next := sched.next(prev)
+			if !next.Equal(expected) {
+				t.Errorf("%q: run %v: expected %v, got %v", tc.spec, i, expected, next)
+				break
+			}
+			prev = next
+		}
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	cases := []struct {
+		exe   Executable
+		valid bool
+	}{
+		{Executable{}, true},
+		{Executable{Schedule: "@every 1m"}, true},
+		{Executable{Schedule: "@every 1m", Overlap: OverlapQueue}, true},
+		{Executable{Schedule: "0 * * * *", Overlap: OverlapReplace}, true},
+		{Executable{Schedule: "0 * * * *", Overlap: "parallel"}, false},
+		{Executable{Schedule: "0 * * *"}, false},
+	}
+	for _, tc := range cases {
+		if err := tc.exe.validateSchedule(); (err == nil) != tc.valid {
+			t.Errorf("%q/%q: expected valid %v, got error %v", tc.exe.Schedule, tc.exe.Overlap, tc.valid, err)
+		}
+	}
+}
@@ -5,11 +5,16 @@
 package pool
 
 import (
+	"errors"
 	"os/exec"
 	"log"
 )
 
-func setAttrs(cmd *exec.Cmd) {
+func setAttrs(cmd *exec.Cmd, id *identity) error {
+	if id != nil {
+		return errors.New("user and group are not supported on this platform")
+	}
+	return nil
 }
 
 func kill(cmd *exec.Cmd, logger *log.Logger) error {
@@ -1,16 +1,24 @@
 package pool
 
 import (
-	"syscall"
-	"os/exec"
 	"log"
+	"os/exec"
+	"syscall"
 )
 
-func setAttrs(cmd *exec.Cmd) {
+func setAttrs(cmd *exec.Cmd, id *identity) error {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Pdeathsig: syscall.SIGKILL,
 		Setpgid:   true,
 	}
+	if id != nil {
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:    id.uid,
+			Gid:    id.gid,
+			Groups: id.groups,
+		}
+	}
+	return nil
 }
 
 func kill(cmd *exec.Cmd, logger *log.Logger) error {
@@ -0,0 +1,31 @@
+// +build !linux
+
+package pool
+
+import (
+	"errors"
+	"os/exec"
+)
+
+const rlimInfinity = ^uint64(0)
+
+var rlimitResources = map[string]int{}
+
+var namespaces = map[string]uintptr{}
+
+// ShimMain does nothing: process attributes are supported only on Linux
+func ShimMain() {}
+
+func (exe *Executable) wrapShim(cmd *exec.Cmd, id *identity) error {
+	if exe.needShim() {
+		return errors.New("process attributes (rlimits, nice, oom_score_adj, umask, no_new_privs) are supported only on Linux")
+	}
+	return nil
+}
+
+func (exe *Executable) isolate(cmd *exec.Cmd) error {
+	if exe.Isolation != nil {
+		return errors.New("isolation is supported only on Linux")
+	}
+	return nil
+}
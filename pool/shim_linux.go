@@ -0,0 +1,162 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+const (
+	rlimInfinity     = ^uint64(0)
+	prSetPdeathsig   = 1
+	prSetNoNewPrivs  = 38
+	prioProcess      = 0
+	rlimitRSS        = 5
+	rlimitNPROC      = 6
+	rlimitMEMLOCK    = 8
+	rlimitLOCKS      = 10
+	rlimitSIGPENDING = 11
+	rlimitMSGQUEUE   = 12
+	rlimitNICE       = 13
+	rlimitRTPRIO     = 14
+	rlimitRTTIME     = 15
+)
+
+// names of resources for rlimits (generic Linux numbers)
+var rlimitResources = map[string]int{
+	"as":         syscall.RLIMIT_AS,
+	"core":       syscall.RLIMIT_CORE,
+	"cpu":        syscall.RLIMIT_CPU,
+	"data":       syscall.RLIMIT_DATA,
+	"fsize":      syscall.RLIMIT_FSIZE,
+	"nofile":     syscall.RLIMIT_NOFILE,
+	"stack":      syscall.RLIMIT_STACK,
+	"rss":        rlimitRSS,
+	"nproc":      rlimitNPROC,
+	"memlock":    rlimitMEMLOCK,
+	"locks":      rlimitLOCKS,
+	"sigpending": rlimitSIGPENDING,
+	"msgqueue":   rlimitMSGQUEUE,
+	"nice":       rlimitNICE,
+	"rtprio":     rlimitRTPRIO,
+	"rttime":     rlimitRTTIME,
+}
+
+// shimInstalled is set by ShimMain: without it supervisor binary can't be used as shim
+var shimInstalled bool
+
+// ShimMain applies process attributes (rlimits, nice, umask...) and replaces itself by service command if
+// current process is started by supervisor as shim. Otherwise does nothing. Must be called at the beginning
+// of main in binaries which use pool, otherwise services with process attributes fail with ErrShimNotInstalled
+func ShimMain() {
+	shimInstalled = true
+	raw, ok := os.LookupEnv(shimEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(shimEnv)
+	// nice, pdeathsig and no_new_privs are attributes of thread, so exec must be done from the same thread
+	runtime.LockOSThread()
+	var spec shimSpec
+	err := json.Unmarshal([]byte(raw), &spec)
+	if err == nil {
+		err = spec.apply()
+	}
+	if err == nil {
+		err = syscall.Exec(spec.Path, os.Args, os.Environ())
+	}
+	fmt.Fprintln(os.Stderr, "monexec shim:", err)
+	os.Exit(127)
+}
+
+func (spec *shimSpec) apply() error {
+	if spec.Mounts != nil {
+		if err := spec.Mounts.apply(); err != nil {
+			return err
+		}
+		path, err := spec.Mounts.resolve(spec.Path)
+		if err != nil {
+			return err
+		}
+		spec.Path = path
+	}
+	if spec.Umask != nil {
+		syscall.Umask(*spec.Umask)
+	}
+	for _, limit := range spec.Rlimits {
+		if err := syscall.Setrlimit(limit.Resource, &syscall.Rlimit{Cur: limit.Cur, Max: limit.Max}); err != nil {
+			return fmt.Errorf("set rlimit %v: %v", limit.Resource, err)
+		}
+	}
+	if spec.Nice != nil {
+		if err := syscall.Setpriority(prioProcess, 0, *spec.Nice); err != nil {
+			return fmt.Errorf("set nice: %v", err)
+		}
+	}
+	if spec.OOMScoreAdj != nil {
+		if err := ioutil.WriteFile("/proc/self/oom_score_adj", []byte(strconv.Itoa(*spec.OOMScoreAdj)), 0644); err != nil {
+			return fmt.Errorf("set oom_score_adj: %v", err)
+		}
+	}
+	if id := spec.Credential; id != nil {
+		groups := make([]int, len(id.Groups))
+		for i, gid := range id.Groups {
+			groups[i] = int(gid)
+		}
+		if err := syscall.Setgroups(groups); err != nil {
+			return fmt.Errorf("set groups: %v", err)
+		}
+		if err := syscall.Setgid(int(id.Gid)); err != nil {
+			return fmt.Errorf("set gid: %v", err)
+		}
+		if err := syscall.Setuid(int(id.Uid)); err != nil {
+			return fmt.Errorf("set uid: %v", err)
+		}
+	}
+	if spec.Pdeathsig != 0 {
+		// parent death signal is cleared after change of credentials
+		if _, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, prSetPdeathsig, uintptr(spec.Pdeathsig), 0); e != 0 {
+			return fmt.Errorf("set pdeathsig: %v", e)
+		}
+	}
+	if spec.NoNewPrivs {
+		if _, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); e != 0 {
+			return fmt.Errorf("set no_new_privs: %v", e)
+		}
+	}
+	return nil
+}
+
+// wrapShim replaces command by shim if process attributes are required. setAttrs should be called before.
+// Shim is the supervisor binary itself, so ShimMain must be called by it
+func (exe *Executable) wrapShim(cmd *exec.Cmd, id *identity) error {
+	if !exe.needShim() || cmd.Err != nil {
+		return nil
+	}
+	if !shimInstalled {
+		return ErrShimNotInstalled
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	spec, err := exe.shimSpec(cmd.Path, id)
+	if err != nil {
+		return err
+	}
+	spec.Pdeathsig = int(cmd.SysProcAttr.Pdeathsig)
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	// credentials are changed by shim after limits: unprivileged user can't raise hard limits
+	cmd.SysProcAttr.Credential = nil
+	cmd.Path = self
+	cmd.Env = append(cmd.Env, shimEnv+"="+string(data))
+	return nil
+}
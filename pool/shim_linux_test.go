@@ -0,0 +1,19 @@
+package pool
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+)
+
+func TestWrapShimNotInstalled(t *testing.T) {
+	exe := &Executable{Command: "true", Umask: "027"}
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if err := exe.wrapShim(cmd, nil); err != ErrShimNotInstalled {
+		t.Fatalf("expected %v, got %v", ErrShimNotInstalled, err)
+	}
+	if cmd.Env != nil {
+		t.Fatal("command is wrapped by shim")
+	}
+}
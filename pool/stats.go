@@ -0,0 +1,129 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStatsInterval = 5 * time.Second
+	defaultStatsHistory  = 120
+)
+
+// Usage - resources used by whole process group of instance at some moment
+type Usage struct {
+	Time       time.Time `json:"time"`
+	CPUSeconds float64   `json:"cpu_seconds"` // user and system CPU time of living processes
+	CPUPercent float64   `json:"cpu_percent"` // CPU usage since previous sample. 100% is one CPU
+	RSS        uint64    `json:"rss_bytes"`   // resident memory
+	OpenFiles  int       `json:"open_files"`  // opened file descriptors
+	Threads    int       `json:"threads"`
+	Processes  int       `json:"processes"`
+	ReadBytes  uint64    `json:"read_bytes"`  // bytes read from storage by living processes
+	WriteBytes uint64    `json:"write_bytes"` // bytes written to storage by living processes
+}
+
+// Stats - history of resources usage of instance (oldest first)
+type Stats struct {
+	Interval time.Duration `json:"interval"`
+	Samples  []Usage       `json:"samples"`
+}
+
+// usageHistory keeps last samples of usage
+type usageHistory struct {
+	lock    sync.Mutex
+	samples []Usage
+	next    int
+	full    bool
+}
+
+func newUsageHistory(size int) *usageHistory {
+	return &usageHistory{samples: make([]Usage, size)}
+}
+
+func (uh *usageHistory) add(usage Usage) {
+	uh.lock.Lock()
+	defer uh.lock.Unlock()
+	uh.samples[uh.next] = usage
+	uh.next = (uh.next + 1) % len(uh.samples)
+	if uh.next == 0 {
+		uh.full = true
+	}
+}
+
+func (uh *usageHistory) all() []Usage {
+	uh.lock.Lock()
+	defer uh.lock.Unlock()
+	var ans = make([]Usage, 0, len(uh.samples))
+	if uh.full {
+		ans = append(ans, uh.samples[uh.next:]...)
+	}
+	return append(ans, uh.samples[:uh.next]...)
+}
+
+func (uh *usageHistory) last() *Usage {
+	uh.lock.Lock()
+	defer uh.lock.Unlock()
+	if !uh.full && uh.next == 0 {
+		return nil
+	}
+	last := uh.samples[(uh.next+len(uh.samples)-1)%len(uh.samples)]
+	return &last
+}
+
+func (exe *Executable) statsInterval() time.Duration {
+	if exe.StatsInterval == 0 {
+		return defaultStatsInterval
+	}
+	return exe.StatsInterval
+}
+
+// sample usage of process group until context canceled. Exceeded thresholds of watchdog are sent to kill
+func (rn *runnable) sample(ctx context.Context, pgid int, kill chan<- error) {
+	interval := rn.Executable.statsInterval()
+	if interval < 0 {
+		return
+	}
+	var wd *watchdog
+	if rn.Executable.hasWatchdog() {
+		wd, _ = rn.Executable.newWatchdog()
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var prev *Usage
+	for {
+		usage, err := sampleGroup(pgid)
+		if err == errSamplingUnsupported {
+			return
+		}
+		if err == nil {
+			if prev != nil {
+				if elapsed := usage.Time.Sub(prev.Time).Seconds(); elapsed > 0 && usage.CPUSeconds > prev.CPUSeconds {
+					usage.CPUPercent = 100 * (usage.CPUSeconds - prev.CPUSeconds) / elapsed
+				}
+			}
+			prev = usage
+			rn.usage.add(*usage)
+			if wd != nil {
+				if reason := wd.check(usage); reason != nil {
+					select {
+					case kill <- reason:
+					default:
+					}
+					return
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stats returns history of resources usage
+func (rn *runnable) Stats() Stats {
+	return Stats{Interval: rn.Executable.statsInterval(), Samples: rn.usage.all()}
+}
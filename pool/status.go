@@ -0,0 +1,95 @@
+package pool
+
+import (
+	"os"
+	"time"
+)
+
+// Status - runtime information about instance
+type Status struct {
+	State        State         `json:"state"`
+	PID          int           `json:"pid,omitempty"`            // PID of current process. 0 if not running
+	StartedAt    *time.Time    `json:"started_at,omitempty"`     // Start time of current (or last) process
+	Uptime       time.Duration `json:"uptime"`                   // Uptime of current process
+	Restarts     int           `json:"restarts"`                 // How much times process was restarted
+	LastExitCode *int          `json:"last_exit_code,omitempty"` // Exit code of last finished process. -1 if terminated by signal
+	LastSignal   string        `json:"last_signal,omitempty"`    // Signal that terminated last finished process
+	LastError    string        `json:"last_error,omitempty"`     // Stop reason of last run
+	Usage        *Usage        `json:"usage,omitempty"`          // Last sample of resources usage of running process
+	LastRun      *time.Time    `json:"last_run,omitempty"`       // Start time of last run of scheduled service
+	NextRun      *time.Time    `json:"next_run,omitempty"`       // Time of next run of scheduled service
+}
+
+// processStarted saves information about just started process
+func (rn *runnable) processStarted(process *os.Process) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.process = process
+	rn.pid = process.Pid
+	rn.startedAt = time.Now()
+	rn.stopSignal = nil // stop signal applies only to process it was sent to
+}
+
+// processExited saves exit status of process
+func (rn *runnable) processExited(err error) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.pid = 0
+	rn.process = nil
+	if code, signal, ok := exitCode(err); ok {
+		rn.lastExitCode = &code
+		rn.lastSignal = signal
+	}
+}
+
+// runFinished saves stop reason of run
+func (rn *runnable) runFinished(reason error) {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.pid = 0
+	rn.lastError = ""
+	if reason != nil {
+		rn.lastError = reason.Error()
+	}
+}
+
+func (rn *runnable) restarted() {
+	rn.lock.Lock()
+	defer rn.lock.Unlock()
+	rn.restarts++
+}
+
+func (rn *runnable) Status() Status {
+	rn.lock.RLock()
+	defer rn.lock.RUnlock()
+	return rn.status()
+}
+
+// status without lock
+func (rn *runnable) status() Status {
+	st := Status{
+		State:        rn.state,
+		PID:          rn.pid,
+		Restarts:     rn.restarts,
+		LastExitCode: rn.lastExitCode,
+		LastSignal:   rn.lastSignal,
+		LastError:    rn.lastError,
+	}
+	if !rn.startedAt.IsZero() {
+		startedAt := rn.startedAt
+		st.StartedAt = &startedAt
+		if rn.pid != 0 {
+			st.Uptime = time.Since(startedAt)
+			st.Usage = rn.usage.last()
+		}
+	}
+	if !rn.lastRun.IsZero() {
+		lastRun := rn.lastRun
+		st.LastRun = &lastRun
+	}
+	if !rn.nextRun.IsZero() {
+		nextRun := rn.nextRun
+		st.NextRun = &nextRun
+	}
+	return st
+}
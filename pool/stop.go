@@ -0,0 +1,109 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const defaultStopSignal = "SIGINT"
+
+// StopStage - one step of termination sequence: send signal and wait for process exit
+type StopStage struct {
+	Signal string        `yaml:"signal"`         // Signal name (SIGTERM, QUIT) or number. SIGKILL kills whole process group
+	Wait   time.Duration `yaml:"wait,omitempty"` // How long to wait before next stage. If not set - stop_timeout
+}
+
+func (exe *Executable) validateStop() error {
+	if exe.StopSignal != "" {
+		if _, err := ParseSignal(exe.StopSignal); err != nil {
+			return fmt.Errorf("stop_signal: %v", err)
+		}
+	}
+	if exe.StopCommand != nil {
+		if err := exe.StopCommand.validate(); err != nil {
+			return fmt.Errorf("stop_command: %v", err)
+		}
+	}
+	for i, stage := range exe.StopSequence {
+		if _, err := ParseSignal(stage.Signal); err != nil {
+			return fmt.Errorf("stop_sequence[%v]: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// stopStages returns termination sequence. Without explicit sequence it's stop_signal and stop_timeout
+// (or nothing if stop_command is used instead of signal)
+func (exe *Executable) stopStages() []StopStage {
+	if len(exe.StopSequence) > 0 {
+		return exe.StopSequence
+	}
+	if exe.StopCommand != nil {
+		return nil
+	}
+	signal := exe.StopSignal
+	if signal == "" {
+		signal = defaultStopSignal
+	}
+	return []StopStage{{Signal: signal, Wait: exe.StopTimeout}}
+}
+
+// stopOrKill gracefully stops process by stop command and/or stop sequence. Process group is killed if process
+// is still alive after all stages
+func (exe *Executable) stopOrKill(cmd *exec.Cmd, env []string, res <-chan error, logger *log.Logger) error {
+	if exe.StopCommand != nil {
+		logger.Println("Running stop command", exe.StopCommand.Command)
+		env = append(env, "MONEXEC_PID="+strconv.Itoa(cmd.Process.Pid))
+		if err := exe.StopCommand.run(context.Background(), exe, env, logger); err != nil {
+			logger.Println("Stop command failed:", err)
+		}
+		if err, ok := waitExit(res, exe.StopTimeout); ok {
+			logger.Println("Process graceful stopped")
+			return err
+		}
+	}
+	for _, stage := range exe.stopStages() {
+		sig, _ := ParseSignal(stage.Signal)
+		if sig == syscall.SIGKILL {
+			break
+		}
+		logger.Println("Sending", signalName(sig))
+		if err := cmd.Process.Signal(sig); err != nil {
+			logger.Println("Failed send", signalName(sig)+":", err)
+		}
+		wait := stage.Wait
+		if wait <= 0 {
+			wait = exe.StopTimeout
+		}
+		if err, ok := waitExit(res, wait); ok {
+			logger.Println("Process graceful stopped")
+			return err
+		}
+		logger.Println("Process graceful shutdown waiting timeout")
+	}
+	return kill(cmd, logger)
+}
+
+func waitExit(res <-chan error, timeout time.Duration) (error, bool) {
+	select {
+	case err := <-res:
+		return err, true
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
+
+// signalName returns short name like SIGTERM
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return "SIG" + name
+		}
+	}
+	return sig.String()
+}
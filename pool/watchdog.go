@@ -0,0 +1,90 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const defaultCPUWindow = 1 * time.Minute
+
+// LimitError - stop reason of process that exceeded resource threshold (max_rss, max_cpu_percent, max_open_files)
+type LimitError struct {
+	Resource string // rss, cpu or open files
+	Limit    string
+	Value    string
+}
+
+func (e *LimitError) Error() string {
+	return "resource limit exceeded: " + e.Resource + " " + e.Value + " > " + e.Limit
+}
+
+// watchdog checks samples of usage against thresholds of service
+type watchdog struct {
+	maxRSS       uint64
+	maxCPU       float64
+	cpuWindow    time.Duration
+	maxOpenFiles int
+	cpuOverSince time.Time
+}
+
+func (exe *Executable) hasWatchdog() bool {
+	return exe.MaxRSS != "" || exe.MaxCPUPercent > 0 || exe.MaxOpenFiles > 0
+}
+
+func (exe *Executable) validateWatchdog() error {
+	if !exe.hasWatchdog() {
+		return nil
+	}
+	if exe.statsInterval() < 0 {
+		return errors.New("resource thresholds require stats sampling (stats_interval)")
+	}
+	_, err := exe.newWatchdog()
+	return err
+}
+
+func (exe *Executable) newWatchdog() (*watchdog, error) {
+	wd := &watchdog{maxCPU: exe.MaxCPUPercent, cpuWindow: exe.MaxCPUWindow, maxOpenFiles: exe.MaxOpenFiles}
+	if exe.MaxRSS != "" {
+		v, err := parseSize(exe.MaxRSS)
+		if err != nil {
+			return nil, fmt.Errorf("max_rss: %v", err)
+		}
+		if v != "max" {
+			wd.maxRSS, _ = strconv.ParseUint(v, 10, 64)
+		}
+	}
+	if wd.cpuWindow <= 0 {
+		wd.cpuWindow = defaultCPUWindow
+	}
+	return wd, nil
+}
+
+// check usage against thresholds. CPU usage should be above limit for whole window
+func (wd *watchdog) check(usage *Usage) error {
+	if wd.maxRSS > 0 && usage.RSS > wd.maxRSS {
+		return &LimitError{Resource: "rss", Limit: formatBytes(wd.maxRSS), Value: formatBytes(usage.RSS)}
+	}
+	if wd.maxOpenFiles > 0 && usage.OpenFiles > wd.maxOpenFiles {
+		return &LimitError{Resource: "open files", Limit: strconv.Itoa(wd.maxOpenFiles), Value: strconv.Itoa(usage.OpenFiles)}
+	}
+	if wd.maxCPU > 0 {
+		if usage.CPUPercent <= wd.maxCPU {
+			wd.cpuOverSince = time.Time{}
+		} else if wd.cpuOverSince.IsZero() {
+			wd.cpuOverSince = usage.Time
+		} else if usage.Time.Sub(wd.cpuOverSince) >= wd.cpuWindow {
+			return &LimitError{
+				Resource: "cpu",
+				Limit:    fmt.Sprintf("%.1f%%", wd.maxCPU),
+				Value:    fmt.Sprintf("%.1f%% for %v", usage.CPUPercent, usage.Time.Sub(wd.cpuOverSince).Truncate(time.Second)),
+			}
+		}
+	}
+	return nil
+}
+
+func formatBytes(v uint64) string {
+	return fmt.Sprintf("%.1fM", float64(v)/(1<<20))
+}